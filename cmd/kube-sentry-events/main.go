@@ -5,16 +5,27 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	sentrygo "github.com/getsentry/sentry-go"
+	"k8s.io/client-go/kubernetes"
+
 	"github.com/imankulov/kube-sentry-events/internal/config"
+	"github.com/imankulov/kube-sentry-events/internal/correlate"
 	"github.com/imankulov/kube-sentry-events/internal/dedup"
 	"github.com/imankulov/kube-sentry-events/internal/filter"
+	"github.com/imankulov/kube-sentry-events/internal/health"
+	"github.com/imankulov/kube-sentry-events/internal/leader"
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/recovery"
 	"github.com/imankulov/kube-sentry-events/internal/sentry"
+	"github.com/imankulov/kube-sentry-events/internal/state"
 	"github.com/imankulov/kube-sentry-events/internal/watcher"
 )
 
@@ -22,13 +33,22 @@ var (
 	version = "dev"
 )
 
+const (
+	// configReloadInterval is how often the config file is checked for changes.
+	configReloadInterval = 10 * time.Second
+)
+
 func main() {
 	// CLI flags
 	var (
-		dryRun     = flag.Bool("dry-run", false, "Print events to stdout instead of sending to Sentry")
-		kubeconfig = flag.String("kubeconfig", "", "Path to kubeconfig file (defaults to in-cluster config or ~/.kube/config)")
-		once       = flag.Bool("once", false, "List matching events once and exit (don't watch)")
-		showVer    = flag.Bool("version", false, "Show version and exit")
+		configPath  = flag.String("config", os.Getenv("KUBE_SENTRY_CONFIG"), "Path to YAML config file with per-reason rules (or KUBE_SENTRY_CONFIG)")
+		dryRun      = flag.Bool("dry-run", false, "Print events to stdout instead of sending to Sentry")
+		kubeconfig  = flag.String("kubeconfig", "", "Path to kubeconfig file (defaults to in-cluster config or ~/.kube/config)")
+		once        = flag.Bool("once", false, "List matching events once and exit (don't watch)")
+		metricsAddr = flag.String("metrics-addr", "", "Address to serve Prometheus /metrics on, e.g. :9090 (or KUBE_SENTRY_METRICS_ADDR)")
+		healthAddr  = flag.String("health-addr", "", "Address to serve /healthz and /readyz on (or KUBE_SENTRY_HEALTH_ADDR, default :8081)")
+		leaderElect = flag.Bool("leader-elect", false, "Enable Lease-based leader election so only one replica sends events (or KUBE_SENTRY_LEADER_ELECT)")
+		showVer     = flag.Bool("version", false, "Show version and exit")
 	)
 	flag.Parse()
 
@@ -38,7 +58,7 @@ func main() {
 	}
 
 	// Load configuration
-	cfg, err := config.Load(*dryRun)
+	cfg, err := config.LoadFile(*configPath, *dryRun)
 	if err != nil {
 		slog.Error("failed to load configuration", "error", err)
 		os.Exit(1)
@@ -47,48 +67,120 @@ func main() {
 	// Set up logger
 	logger := setupLogger(cfg.LogLevel, *dryRun)
 
+	if *leaderElect {
+		cfg.LeaderElection = true
+	}
+	if *metricsAddr != "" {
+		cfg.MetricsAddr = *metricsAddr
+	}
+	if *healthAddr != "" {
+		cfg.HealthAddr = *healthAddr
+	}
+
 	logger.Info("starting kube-sentry-events",
 		"version", version,
 		"dry_run", *dryRun,
 		"once", *once,
 		"environment", cfg.SentryEnvironment,
+		"sentry_routes", len(cfg.SentryRoutes),
 		"namespaces", cfg.Namespaces,
 		"exclude_namespaces", cfg.ExcludeNamespaces,
 		"event_reasons", cfg.EventReasons,
 		"dedup_window", cfg.DedupWindow,
+		"aggregation_window", cfg.AggregationWindow,
+		"leader_election", cfg.LeaderElection,
+		"watch_pod_status", cfg.WatchPodStatus,
+		"watch_nodes", cfg.WatchNodes,
+		"watch_jobs", cfg.WatchJobs,
+		"auto_resolve", cfg.AutoResolve,
+		"resolve_via_api", cfg.SentryAuthToken != "",
+		"state_configmap", cfg.StateConfigMap,
+		"state_dir", cfg.StateDir,
 	)
 
+	// Health probes track Sentry initialisation and watch activity
+	checker := health.New(cfg.HealthMaxIdle)
+
 	// Initialize sender (Sentry or stdout)
 	var sender watcher.EventSender
 	var sentrySender *sentry.Sender
+	var guide guidance
 	if *dryRun {
-		sender = sentry.NewDryRunSender(os.Stdout)
+		dryRunSender := sentry.NewDryRunSender(os.Stdout)
+		sender, guide = dryRunSender, dryRunSender
 		logger.Info("dry-run mode enabled, events will be printed to stdout")
+		checker.SetSentryReady(true)
 	} else {
 		var err error
-		sentrySender, err = sentry.New(cfg.SentryDSN, cfg.SentryEnvironment, cfg.EnableLogs)
+		sentrySender, err = sentry.New(cfg.SentryDSN, cfg.SentryEnvironment, cfg.EnableLogs, sentryRoutes(cfg))
 		if err != nil {
 			logger.Error("failed to initialize Sentry", "error", err)
 			os.Exit(1)
 		}
-		sender = sentrySender
+		sender, guide = sentrySender, sentrySender
+		checker.SetSentryReady(true)
 		if cfg.EnableLogs {
 			logger.Info("Sentry Logs enabled - all events will be logged for observability")
 		}
 	}
+	guide.SetTroubleshooting(troubleshootingOverrides(cfg))
+	guide.SetRunbooks(cfg.NamespaceRunbooks)
 
 	// Initialize filter
-	eventFilter := filter.New(cfg.Namespaces, cfg.ExcludeNamespaces, cfg.EventReasons, cfg.EventThresholds)
+	eventFilter, err := newFilter(cfg)
+	if err != nil {
+		logger.Error("failed to initialize filter", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize deduplicator
 	deduplicator := dedup.New(cfg.DedupWindow)
+	defer deduplicator.Close()
+	deduplicator.SetMaxEntries(cfg.DedupMaxEntries)
+	deduplicator.SetFlapping(cfg.FlappingThreshold, cfg.FlappingPeriod)
+	deduplicator.SetRenotifyInterval(cfg.RenotifyInterval)
+	metrics.RegisterDedupSize(deduplicator.Size)
 
 	// Initialize watcher
-	eventWatcher, err := watcher.New(eventFilter, deduplicator, sender, logger, *kubeconfig)
+	client, err := watcher.NewClient(*kubeconfig)
 	if err != nil {
-		logger.Error("failed to create watcher", "error", err)
+		logger.Error("failed to create Kubernetes client", "error", err)
 		os.Exit(1)
 	}
+	// Aggregate bursts across many workloads into a single issue
+	var correlator *correlate.Correlator
+	if cfg.AggregationWindow > 0 {
+		correlator = correlate.New(sender, cfg.AggregationWindow, cfg.AggregationMinWorkloads, cfg.AggregationReasons)
+		sender = correlator
+	}
+
+	eventWatcher := watcher.New(eventFilter, deduplicator, sender, logger, client)
+	eventWatcher.SetHealth(checker)
+	eventWatcher.SetWatchPodStatus(cfg.WatchPodStatus)
+	eventWatcher.SetWatchNodes(cfg.WatchNodes)
+	eventWatcher.SetWatchJobs(cfg.WatchJobs)
+	eventWatcher.SetEscalateFlapping(cfg.FlappingEscalate)
+	eventWatcher.SetManifestReasons(cfg.ManifestReasons)
+	eventWatcher.SetPreviousLogs(previousLogs(cfg))
+
+	// Keep dedup state across restarts so ongoing problems aren't re-alerted
+	if store := newStateStore(cfg, client, "dedup.json"); store != nil && !*once {
+		deduplicator.SetStore(store)
+		eventWatcher.SetSnapshotInterval(cfg.StateSaveInterval)
+	}
+
+	// Track issues so they can be resolved once the workload recovers
+	if sentrySender != nil && cfg.AutoResolve && !*once {
+		tracker := recovery.NewTracker(newStateStore(cfg, client, "open-issues.json"))
+		sentrySender.SetTracker(tracker)
+		metrics.RegisterOpenIssues(tracker.Len)
+
+		var resolver watcher.IssueResolver
+		if cfg.SentryAuthToken != "" {
+			resolver = sentry.NewAPIClient(cfg.SentryURL, cfg.SentryOrg, cfg.SentryAuthToken)
+		}
+		eventWatcher.SetRecovery(tracker, resolver, cfg.AutoResolveInterval)
+	}
 
 	// Set up context with signal handling
 	ctx, cancel := context.WithCancel(context.Background())
@@ -103,12 +195,68 @@ func main() {
 		cancel()
 	}()
 
+	// Hot-reload filter rules when the config file changes or on SIGHUP
+	if *configPath != "" && !*once {
+		hupCh := make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
+		reloader := config.NewReloader(*configPath, *dryRun, configReloadInterval, logger)
+		go reloader.Run(ctx, hupCh, func(newCfg *config.Config) {
+			f, err := newFilter(newCfg)
+			if err != nil {
+				logger.Error("invalid filter config, keeping previous config", "error", err)
+				return
+			}
+			eventWatcher.SetFilter(f)
+			eventWatcher.SetManifestReasons(newCfg.ManifestReasons)
+			eventWatcher.SetPreviousLogs(previousLogs(newCfg))
+			guide.SetTroubleshooting(troubleshootingOverrides(newCfg))
+			guide.SetRunbooks(newCfg.NamespaceRunbooks)
+			logger.Info("config reloaded",
+				"namespaces", newCfg.Namespaces,
+				"exclude_namespaces", newCfg.ExcludeNamespaces,
+				"event_reasons", newCfg.EventReasons,
+			)
+		})
+	}
+
+	// Serve Prometheus metrics and health probes (sharing a listener if the addresses match)
+	muxes := make(map[string]*http.ServeMux)
+	muxFor := func(addr string) *http.ServeMux {
+		if _, ok := muxes[addr]; !ok {
+			muxes[addr] = http.NewServeMux()
+		}
+		return muxes[addr]
+	}
+	if cfg.MetricsAddr != "" {
+		muxFor(cfg.MetricsAddr).Handle("/metrics", metrics.Handler())
+	}
+	if cfg.HealthAddr != "" && !*once {
+		checker.Register(muxFor(cfg.HealthAddr))
+	}
+	for addr, mux := range muxes {
+		go serveHTTP(ctx, addr, mux, logger)
+	}
+
 	// Run in appropriate mode
 	if *once {
 		if err := eventWatcher.ListOnce(ctx); err != nil {
 			logger.Error("list error", "error", err)
 			os.Exit(1)
 		}
+	} else if cfg.LeaderElection && !*dryRun {
+		leaderCfg := leader.DefaultConfig(cfg.LeaseName, cfg.LeaseNamespace, cfg.Identity)
+		checker.SetStandby(true)
+		err := leader.Run(ctx, client, leaderCfg, logger, func(ctx context.Context, cutoff time.Time) {
+			checker.SetStandby(false)
+			defer checker.SetStandby(true)
+			if err := eventWatcher.RunSince(ctx, cutoff); err != nil && err != context.Canceled {
+				logger.Error("watcher error", "error", err)
+			}
+		})
+		if err != nil && err != context.Canceled {
+			logger.Error("leader election error", "error", err)
+			os.Exit(1)
+		}
 	} else {
 		if err := eventWatcher.Run(ctx); err != nil && err != context.Canceled {
 			logger.Error("watcher error", "error", err)
@@ -116,7 +264,10 @@ func main() {
 		}
 	}
 
-	// Flush Sentry events before exit
+	// Send buffered bursts, then flush Sentry events before exit
+	if correlator != nil {
+		correlator.Close()
+	}
 	if sentrySender != nil {
 		logger.Info("flushing events to Sentry...")
 		if ok := sentrySender.Flush(5 * time.Second); ok {
@@ -129,6 +280,103 @@ func main() {
 	logger.Info("shutdown complete")
 }
 
+// newFilter builds the event filter from the configuration rules.
+func newFilter(cfg *config.Config) (*filter.Filter, error) {
+	f := filter.New(cfg.Namespaces, cfg.ExcludeNamespaces, cfg.EventReasons, cfg.EventThresholds)
+	if err := f.SetSelectors(cfg.NamespaceSelector, cfg.ExcludeNamespaceSelector, cfg.ObjectSelector, cfg.ExcludeObjectSelector); err != nil {
+		return nil, err
+	}
+
+	severities := make(map[string]sentrygo.Level, len(cfg.Severities))
+	for reason, level := range cfg.Severities {
+		severities[reason] = sentrygo.Level(level)
+	}
+	f.SetSeverities(severities)
+	f.SetDedupWindows(cfg.DedupWindows)
+
+	return f, nil
+}
+
+// newStateStore returns where state saved under key persists: a key in the
+// state ConfigMap, a file in the state directory, or nil to keep it in memory.
+func newStateStore(cfg *config.Config, client kubernetes.Interface, key string) state.Store {
+	switch {
+	case cfg.StateConfigMap != "":
+		return state.NewConfigMapStore(client, cfg.StateNamespace, cfg.StateConfigMap, key)
+	case cfg.StateDir != "":
+		return state.NewFileStore(filepath.Join(cfg.StateDir, key))
+	default:
+		return nil
+	}
+}
+
+// sentryRoutes converts config routes into sender routes.
+func sentryRoutes(cfg *config.Config) []sentry.Route {
+	routes := make([]sentry.Route, 0, len(cfg.SentryRoutes))
+	for _, r := range cfg.SentryRoutes {
+		routes = append(routes, sentry.Route{
+			Name:              r.Name,
+			DSN:               r.DSN,
+			Namespaces:        r.Namespaces,
+			NamespaceSelector: r.NamespaceSelector,
+		})
+	}
+	return routes
+}
+
+// previousLogs converts the config's container log settings for the watcher.
+func previousLogs(cfg *config.Config) watcher.PreviousLogs {
+	return watcher.PreviousLogs{
+		Reasons:    cfg.PreviousLogsReasons,
+		Namespaces: cfg.PreviousLogsNamespaces,
+		TailLines:  cfg.PreviousLogsTailLines,
+		MaxBytes:   cfg.PreviousLogsMaxBytes,
+		Timeout:    cfg.PreviousLogsTimeout,
+	}
+}
+
+// guidance is implemented by both senders, which render troubleshooting with
+// the configured overrides.
+type guidance interface {
+	SetTroubleshooting(overrides map[string]sentry.TroubleshootingContext)
+	SetRunbooks(runbooks map[string]string)
+}
+
+// troubleshootingOverrides converts config rules into sender troubleshooting overrides.
+func troubleshootingOverrides(cfg *config.Config) map[string]sentry.TroubleshootingContext {
+	overrides := make(map[string]sentry.TroubleshootingContext, len(cfg.Troubleshooting))
+	for reason, t := range cfg.Troubleshooting {
+		overrides[reason] = sentry.TroubleshootingContext{
+			Description:   t.Description,
+			LikelyCauses:  t.LikelyCauses,
+			DebugCommands: t.DebugCommands,
+			RunbookURL:    t.RunbookURL,
+		}
+	}
+	return overrides
+}
+
+// serveHTTP runs an HTTP server until the context is cancelled.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler, logger *slog.Logger) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("serving HTTP", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Error("HTTP server error", "addr", addr, "error", err)
+	}
+}
+
 func setupLogger(level string, humanReadable bool) *slog.Logger {
 	var logLevel slog.Level
 	switch strings.ToLower(level) {
@@ -0,0 +1,98 @@
+// Package cache provides a size-bounded cache whose entries expire after a
+// fixed TTL.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// DefaultMaxEntries is a bound suitable for per-object API lookups.
+const DefaultMaxEntries = 1000
+
+// entry is a cached value and when it expires.
+type entry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// Cache maps keys to values for a fixed TTL, holding at most maxEntries.
+// Every entry lives for the same TTL, so the least recently stored entry is
+// always the next to expire: expiring and evicting are both O(1).
+type Cache[V any] struct {
+	ttl        time.Duration
+	maxEntries int
+	clock      clock.PassiveClock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently stored first
+}
+
+// New creates a cache whose entries expire after ttl.
+func New[V any](ttl time.Duration, maxEntries int) *Cache[V] {
+	return NewWithClock[V](ttl, maxEntries, clock.RealClock{})
+}
+
+// NewWithClock is like New but reads the time from clk, for tests.
+func NewWithClock[V any](ttl time.Duration, maxEntries int, clk clock.PassiveClock) *Cache[V] {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Cache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		clock:      clk,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value stored for key, if it hasn't expired.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*entry[V])
+		if c.clock.Now().Before(e.expiresAt) {
+			return e.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// Set stores value for key, replacing any previous value and restarting its
+// TTL. Expired entries are dropped, then the least recently stored ones while
+// the cache is over its bound.
+func (c *Cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, expiresAt: now.Add(c.ttl)})
+
+	for elem := c.order.Back(); elem != nil; elem = c.order.Back() {
+		e := elem.Value.(*entry[V])
+		if now.Before(e.expiresAt) && c.order.Len() <= c.maxEntries {
+			break
+		}
+		c.order.Remove(elem)
+		delete(c.entries, e.key)
+	}
+}
+
+// Len returns the number of stored entries, including expired ones not yet dropped.
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+)
+
+func TestCache_Expires(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	c := NewWithClock[int](time.Minute, 10, clk)
+
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Fatalf("expected a=1, got %d (%v)", v, ok)
+	}
+
+	clk.SetTime(clk.Now().Add(time.Minute))
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to expire")
+	}
+
+	// Expired entries are dropped on the next store
+	c.Set("b", 2)
+	if c.Len() != 1 {
+		t.Errorf("expected 1 entry, got %d", c.Len())
+	}
+}
+
+func TestCache_BoundsSize(t *testing.T) {
+	c := New[int](time.Hour, 3)
+
+	for i := 0; i < 5; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+
+	if c.Len() != 3 {
+		t.Errorf("expected 3 entries, got %d", c.Len())
+	}
+	for _, evicted := range []string{"0", "1"} {
+		if _, ok := c.Get(evicted); ok {
+			t.Errorf("expected %s to be evicted", evicted)
+		}
+	}
+	if v, ok := c.Get("4"); !ok || v != 4 {
+		t.Errorf("expected newest entry to be kept, got %d (%v)", v, ok)
+	}
+}
+
+func TestCache_SetRestartsTTL(t *testing.T) {
+	clk := clocktesting.NewFakePassiveClock(time.Now())
+	c := NewWithClock[int](time.Minute, 2, clk)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	clk.SetTime(clk.Now().Add(30 * time.Second))
+	c.Set("a", 3)
+	c.Set("c", 4)
+
+	// b was stored least recently, so it's evicted first
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 3 {
+		t.Errorf("expected a=3, got %d (%v)", v, ok)
+	}
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // Config holds the application configuration.
@@ -12,11 +15,34 @@ type Config struct {
 	// Sentry configuration
 	SentryDSN         string
 	SentryEnvironment string
+	// Per-namespace or per-team destinations; SentryDSN is the default
+	SentryRoutes []SentryRoute
+	// Sentry Web API access for resolving issues (empty token disables it)
+	SentryURL       string
+	SentryOrg       string
+	SentryAuthToken string
+
+	// Resolve issues (or at least log a recovery) once the workload is healthy again
+	AutoResolve         bool
+	AutoResolveInterval time.Duration
+	// Where open issues and dedup state persist across restarts: a ConfigMap
+	// or a local directory (both empty keep them in memory)
+	StateConfigMap    string
+	StateNamespace    string
+	StateDir          string
+	StateSaveInterval time.Duration
 
 	// Namespace filtering
 	Namespaces        []string // Empty means all namespaces
 	ExcludeNamespaces []string
 
+	// Label selectors (Kubernetes selector syntax) on the event's namespace and
+	// involved object chain. Empty means no selector.
+	NamespaceSelector        string
+	ExcludeNamespaceSelector string
+	ObjectSelector           string
+	ExcludeObjectSelector    string
+
 	// Event filtering
 	EventReasons []string
 
@@ -24,14 +50,72 @@ type Config struct {
 	// Events below threshold still go to Sentry Logs for observability
 	EventThresholds map[string]int32
 
+	// Per-reason overrides from the config file rules
+	Severities      map[string]string        // Sentry level (debug, info, warning, error, fatal)
+	DedupWindows    map[string]time.Duration // Overrides DedupWindow for the reason
+	Troubleshooting map[string]Troubleshooting
+	// Runbook URL templates per namespace, taking precedence over the reason's
+	NamespaceRunbooks map[string]string
+
+	// Detect container terminations from Pod status in addition to Events
+	WatchPodStatus bool
+	// Detect Node condition transitions (NotReady, memory/disk/PID pressure)
+	WatchNodes bool
+	// Detect failed Jobs from their status conditions
+	WatchJobs bool
+
 	// Enable Sentry Logs for all events (observability mode)
 	EnableLogs bool
 
 	// Deduplication
-	DedupWindow time.Duration
+	DedupWindow     time.Duration
+	DedupMaxEntries int // Distinct events remembered; the least recently seen are evicted
+	// Flag events whose dedup window reopens FlappingThreshold times within
+	// FlappingPeriod (a zero threshold disables it), optionally raising their
+	// Sentry level by one step
+	FlappingThreshold int
+	FlappingPeriod    time.Duration
+	FlappingEscalate  bool
+	// Send a reminder to an ongoing issue every RenotifyInterval while it keeps
+	// firing (zero disables reminders)
+	RenotifyInterval time.Duration
+
+	// Send a single issue when AggregationMinWorkloads distinct workloads hit
+	// one of AggregationReasons on the same node or namespace within
+	// AggregationWindow (a zero window disables it)
+	AggregationWindow       time.Duration
+	AggregationMinWorkloads int
+	AggregationReasons      []string
+
+	// Attach a sanitised snapshot of the involved object to issues for
+	// ManifestReasons (empty disables it)
+	ManifestReasons []string
+
+	// Attach the last PreviousLogsTailLines lines (at most PreviousLogsMaxBytes,
+	// fetched within PreviousLogsTimeout) of a crashed container's logs to
+	// issues for PreviousLogsReasons in PreviousLogsNamespaces
+	PreviousLogsReasons    []string
+	PreviousLogsNamespaces []string
+	PreviousLogsTailLines  int64
+	PreviousLogsMaxBytes   int64
+	PreviousLogsTimeout    time.Duration
 
 	// Logging
 	LogLevel string
+
+	// Address for the Prometheus /metrics listener (empty disables it)
+	MetricsAddr string
+
+	// Address for the /healthz and /readyz probes (empty disables them)
+	HealthAddr string
+	// Liveness fails if no watch event or bookmark arrives within this period
+	HealthMaxIdle time.Duration
+
+	// Leader election - only the lease holder watches events
+	LeaderElection bool
+	LeaseName      string
+	LeaseNamespace string
+	Identity       string
 }
 
 // DefaultEventReasons returns the default list of event reasons to monitor.
@@ -51,6 +135,17 @@ func DefaultEventReasons() []string {
 		"ErrImagePull",
 		"BackOff",
 		"FailedCreate",
+		// Node conditions from the node watcher
+		"NodeNotReady",
+		"NodeMemoryPressure",
+		"NodeDiskPressure",
+		"NodePIDPressure",
+		// Failed Jobs from the job watcher and the job controller
+		"BackoffLimitExceeded",
+		"DeadlineExceeded",
+		"JobFailed",
+		// Non-zero container exits from the pod status watcher
+		"ContainerCrashed",
 	}
 }
 
@@ -67,6 +162,14 @@ func DefaultEventThresholds() map[string]int32 {
 		"FailedMount":        1,
 		"FailedAttachVolume": 1,
 
+		// Each failed Job run is reported; dedup groups runs of a CronJob
+		"BackoffLimitExceeded": 1,
+		"DeadlineExceeded":     1,
+		"JobFailed":            1,
+
+		// Every non-zero exit is reported; dedup groups restarts of a workload
+		"ContainerCrashed": 1,
+
 		// Require multiple occurrences - often transient during startup/deployment
 		"Unhealthy":        5, // Probe failures are common during rolling updates
 		"BackOff":          3, // Container restarts may be temporary
@@ -79,10 +182,28 @@ func DefaultEventThresholds() map[string]int32 {
 // Load reads configuration from environment variables.
 // If dryRun is true, SENTRY_DSN is not required.
 func Load(dryRun bool) (*Config, error) {
+	return LoadFile("", dryRun)
+}
+
+// LoadFile reads configuration from the YAML file at path (if not empty),
+// then applies environment variable overrides on top.
+// If dryRun is true, SENTRY_DSN is not required.
+func LoadFile(path string, dryRun bool) (*Config, error) {
+	file := &fileConfig{}
+	if path != "" {
+		var err error
+		file, err = readFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	cfg := &Config{
-		SentryDSN:         os.Getenv("SENTRY_DSN"),
-		SentryEnvironment: getEnvOrDefault("SENTRY_ENVIRONMENT", "production"),
-		LogLevel:          getEnvOrDefault("KUBE_SENTRY_LOG_LEVEL", "info"),
+		SentryDSN:         getEnvOrDefault("SENTRY_DSN", file.Sentry.DSN),
+		SentryEnvironment: getEnvOrDefault("SENTRY_ENVIRONMENT", orDefault(file.Sentry.Environment, "production")),
+		LogLevel:          getEnvOrDefault("KUBE_SENTRY_LOG_LEVEL", orDefault(file.LogLevel, "info")),
+		MetricsAddr:       os.Getenv("KUBE_SENTRY_METRICS_ADDR"),
+		HealthAddr:        getEnvOrDefault("KUBE_SENTRY_HEALTH_ADDR", ":8081"),
 	}
 
 	// Validate required fields (skip in dry-run mode)
@@ -90,26 +211,72 @@ func Load(dryRun bool) (*Config, error) {
 		return nil, fmt.Errorf("SENTRY_DSN environment variable is required (use --dry-run to skip)")
 	}
 
+	cfg.SentryURL = getEnvOrDefault("SENTRY_URL", file.Sentry.URL)
+	cfg.SentryOrg = getEnvOrDefault("SENTRY_ORG", file.Sentry.Org)
+	cfg.SentryAuthToken = os.Getenv("SENTRY_AUTH_TOKEN")
+	if cfg.SentryAuthToken != "" && cfg.SentryOrg == "" {
+		return nil, fmt.Errorf("SENTRY_ORG is required when SENTRY_AUTH_TOKEN is set")
+	}
+
+	if err := validateRoutes(file.Sentry.Routes); err != nil {
+		return nil, err
+	}
+	cfg.SentryRoutes = file.Sentry.Routes
+
 	// Parse namespaces
+	cfg.Namespaces = file.Namespaces
 	if ns := os.Getenv("KUBE_SENTRY_NAMESPACES"); ns != "" {
 		cfg.Namespaces = splitAndTrim(ns)
 	}
 
 	if excludeNs := os.Getenv("KUBE_SENTRY_EXCLUDE_NAMESPACES"); excludeNs != "" {
 		cfg.ExcludeNamespaces = splitAndTrim(excludeNs)
+	} else if file.ExcludeNamespaces != nil {
+		cfg.ExcludeNamespaces = file.ExcludeNamespaces
 	} else {
 		cfg.ExcludeNamespaces = []string{"kube-system"}
 	}
 
+	// Parse label selectors
+	selectors := []struct {
+		env   string
+		value string
+		dest  *string
+	}{
+		{"KUBE_SENTRY_NAMESPACE_SELECTOR", file.NamespaceSelector, &cfg.NamespaceSelector},
+		{"KUBE_SENTRY_EXCLUDE_NAMESPACE_SELECTOR", file.ExcludeNamespaceSelector, &cfg.ExcludeNamespaceSelector},
+		{"KUBE_SENTRY_OBJECT_SELECTOR", file.ObjectSelector, &cfg.ObjectSelector},
+		{"KUBE_SENTRY_EXCLUDE_OBJECT_SELECTOR", file.ExcludeObjectSelector, &cfg.ExcludeObjectSelector},
+	}
+	for _, sel := range selectors {
+		*sel.dest = getEnvOrDefault(sel.env, sel.value)
+		if _, err := labels.Parse(*sel.dest); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", sel.env, err)
+		}
+	}
+
 	// Parse event reasons
 	if events := os.Getenv("KUBE_SENTRY_EVENTS"); events != "" {
 		cfg.EventReasons = splitAndTrim(events)
+	} else if len(file.Reasons) > 0 {
+		cfg.EventReasons = file.Reasons
 	} else {
 		cfg.EventReasons = DefaultEventReasons()
 	}
 
-	// Parse event thresholds (format: "Reason:count,Reason:count")
+	// Apply per-reason rules from the config file
 	cfg.EventThresholds = DefaultEventThresholds()
+	if err := applyRules(cfg, file.Rules); err != nil {
+		return nil, err
+	}
+	for namespace, runbook := range file.NamespaceRunbooks {
+		if err := validateTemplate(runbook); err != nil {
+			return nil, fmt.Errorf("invalid runbook for namespace %s: %w", namespace, err)
+		}
+	}
+	cfg.NamespaceRunbooks = file.NamespaceRunbooks
+
+	// Parse event thresholds (format: "Reason:count,Reason:count")
 	if thresholds := os.Getenv("KUBE_SENTRY_THRESHOLDS"); thresholds != "" {
 		for _, item := range splitAndTrim(thresholds) {
 			parts := strings.SplitN(item, ":", 2)
@@ -126,17 +293,240 @@ func Load(dryRun bool) (*Config, error) {
 	}
 
 	// Parse enable logs (default: true for observability)
-	enableLogsStr := getEnvOrDefault("KUBE_SENTRY_ENABLE_LOGS", "true")
+	enableLogsDefault := "true"
+	if file.Sentry.EnableLogs != nil && !*file.Sentry.EnableLogs {
+		enableLogsDefault = "false"
+	}
+	enableLogsStr := getEnvOrDefault("KUBE_SENTRY_ENABLE_LOGS", enableLogsDefault)
 	cfg.EnableLogs = enableLogsStr == "true" || enableLogsStr == "1"
 
+	// Parse pod status watching (default: true)
+	watchPodsDefault := "true"
+	if file.WatchPodStatus != nil && !*file.WatchPodStatus {
+		watchPodsDefault = "false"
+	}
+	watchPodsStr := getEnvOrDefault("KUBE_SENTRY_WATCH_POD_STATUS", watchPodsDefault)
+	cfg.WatchPodStatus = watchPodsStr == "true" || watchPodsStr == "1"
+
+	// Parse node watching (default: true)
+	watchNodesDefault := "true"
+	if file.WatchNodes != nil && !*file.WatchNodes {
+		watchNodesDefault = "false"
+	}
+	watchNodesStr := getEnvOrDefault("KUBE_SENTRY_WATCH_NODES", watchNodesDefault)
+	cfg.WatchNodes = watchNodesStr == "true" || watchNodesStr == "1"
+
+	// Parse job watching (default: true)
+	watchJobsDefault := "true"
+	if file.WatchJobs != nil && !*file.WatchJobs {
+		watchJobsDefault = "false"
+	}
+	watchJobsStr := getEnvOrDefault("KUBE_SENTRY_WATCH_JOBS", watchJobsDefault)
+	cfg.WatchJobs = watchJobsStr == "true" || watchJobsStr == "1"
+
+	// Parse auto-resolve (default: true)
+	autoResolveDefault := "true"
+	if file.AutoResolve.Enabled != nil && !*file.AutoResolve.Enabled {
+		autoResolveDefault = "false"
+	}
+	autoResolveStr := getEnvOrDefault("KUBE_SENTRY_AUTO_RESOLVE", autoResolveDefault)
+	cfg.AutoResolve = autoResolveStr == "true" || autoResolveStr == "1"
+
+	intervalStr := getEnvOrDefault("KUBE_SENTRY_AUTO_RESOLVE_INTERVAL", orDefault(file.AutoResolve.Interval, "1m"))
+	interval, err := time.ParseDuration(intervalStr)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("invalid KUBE_SENTRY_AUTO_RESOLVE_INTERVAL %q: must be a positive duration", intervalStr)
+	}
+	cfg.AutoResolveInterval = interval
+	cfg.StateConfigMap = getEnvOrDefault("KUBE_SENTRY_STATE_CONFIGMAP", file.StateConfigMap)
+	cfg.StateNamespace = getEnvOrDefault("KUBE_SENTRY_STATE_NAMESPACE", getEnvOrDefault("POD_NAMESPACE", "default"))
+	cfg.StateDir = getEnvOrDefault("KUBE_SENTRY_STATE_DIR", file.StateDir)
+	if cfg.StateConfigMap != "" && cfg.StateDir != "" {
+		return nil, fmt.Errorf("KUBE_SENTRY_STATE_CONFIGMAP and KUBE_SENTRY_STATE_DIR are mutually exclusive")
+	}
+
+	saveIntervalStr := getEnvOrDefault("KUBE_SENTRY_STATE_SAVE_INTERVAL", orDefault(file.StateSaveInterval, "1m"))
+	saveInterval, err := time.ParseDuration(saveIntervalStr)
+	if err != nil || saveInterval <= 0 {
+		return nil, fmt.Errorf("invalid KUBE_SENTRY_STATE_SAVE_INTERVAL %q: must be a positive duration", saveIntervalStr)
+	}
+	cfg.StateSaveInterval = saveInterval
+
 	// Parse dedup window
-	dedupStr := getEnvOrDefault("KUBE_SENTRY_DEDUP_WINDOW", "5m")
+	dedupStr := getEnvOrDefault("KUBE_SENTRY_DEDUP_WINDOW", orDefault(file.DedupWindow, "5m"))
 	dedupWindow, err := time.ParseDuration(dedupStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid KUBE_SENTRY_DEDUP_WINDOW: %w", err)
 	}
 	cfg.DedupWindow = dedupWindow
 
+	// Parse dedup cache size
+	cfg.DedupMaxEntries = 10000
+	if file.DedupMaxEntries != 0 {
+		cfg.DedupMaxEntries = file.DedupMaxEntries
+	}
+	if s := os.Getenv("KUBE_SENTRY_DEDUP_MAX_ENTRIES"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KUBE_SENTRY_DEDUP_MAX_ENTRIES %q: expected integer", s)
+		}
+		cfg.DedupMaxEntries = n
+	}
+	if cfg.DedupMaxEntries <= 0 {
+		return nil, fmt.Errorf("invalid dedup max entries %d: must be positive", cfg.DedupMaxEntries)
+	}
+
+	// Parse flapping detection (default: 3 windows within an hour, tag only)
+	cfg.FlappingThreshold = 3
+	if file.Flapping.Threshold != nil {
+		cfg.FlappingThreshold = *file.Flapping.Threshold
+	}
+	if s := os.Getenv("KUBE_SENTRY_FLAPPING_THRESHOLD"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KUBE_SENTRY_FLAPPING_THRESHOLD %q: expected integer", s)
+		}
+		cfg.FlappingThreshold = n
+	}
+	if cfg.FlappingThreshold < 0 {
+		return nil, fmt.Errorf("invalid flapping threshold %d: must not be negative", cfg.FlappingThreshold)
+	}
+
+	flapPeriodStr := getEnvOrDefault("KUBE_SENTRY_FLAPPING_PERIOD", orDefault(file.Flapping.Period, "1h"))
+	flapPeriod, err := time.ParseDuration(flapPeriodStr)
+	if err != nil || flapPeriod <= 0 {
+		return nil, fmt.Errorf("invalid KUBE_SENTRY_FLAPPING_PERIOD %q: must be a positive duration", flapPeriodStr)
+	}
+	cfg.FlappingPeriod = flapPeriod
+
+	escalateDefault := "false"
+	if file.Flapping.Escalate != nil && *file.Flapping.Escalate {
+		escalateDefault = "true"
+	}
+	escalateStr := getEnvOrDefault("KUBE_SENTRY_FLAPPING_ESCALATE", escalateDefault)
+	cfg.FlappingEscalate = escalateStr == "true" || escalateStr == "1"
+
+	// Parse reminder interval (default: disabled)
+	renotifyStr := getEnvOrDefault("KUBE_SENTRY_RENOTIFY_INTERVAL", orDefault(file.RenotifyInterval, "0"))
+	renotify, err := time.ParseDuration(renotifyStr)
+	if err != nil || renotify < 0 {
+		return nil, fmt.Errorf("invalid KUBE_SENTRY_RENOTIFY_INTERVAL %q: must be a duration, 0 to disable", renotifyStr)
+	}
+	cfg.RenotifyInterval = renotify
+
+	// Parse burst aggregation (default: disabled; 5 workloads once a window is set)
+	aggWindowStr := getEnvOrDefault("KUBE_SENTRY_AGGREGATION_WINDOW", orDefault(file.Aggregation.Window, "0"))
+	aggWindow, err := time.ParseDuration(aggWindowStr)
+	if err != nil || aggWindow < 0 {
+		return nil, fmt.Errorf("invalid KUBE_SENTRY_AGGREGATION_WINDOW %q: must be a duration, 0 to disable", aggWindowStr)
+	}
+	cfg.AggregationWindow = aggWindow
+
+	cfg.AggregationMinWorkloads = 5
+	if file.Aggregation.MinWorkloads != 0 {
+		cfg.AggregationMinWorkloads = file.Aggregation.MinWorkloads
+	}
+	if s := os.Getenv("KUBE_SENTRY_AGGREGATION_MIN_WORKLOADS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KUBE_SENTRY_AGGREGATION_MIN_WORKLOADS %q: expected integer", s)
+		}
+		cfg.AggregationMinWorkloads = n
+	}
+	if cfg.AggregationMinWorkloads < 2 {
+		return nil, fmt.Errorf("invalid aggregation min workloads %d: must be at least 2", cfg.AggregationMinWorkloads)
+	}
+
+	if reasons := os.Getenv("KUBE_SENTRY_AGGREGATION_REASONS"); reasons != "" {
+		cfg.AggregationReasons = splitAndTrim(reasons)
+	} else if file.Aggregation.Reasons != nil {
+		cfg.AggregationReasons = file.Aggregation.Reasons
+	} else {
+		cfg.AggregationReasons = []string{"FailedScheduling", "Evicted"}
+	}
+
+	// Parse manifest snapshots (default: off; once enabled, mount and scheduling failures)
+	manifestDefault := "false"
+	if file.Manifest.Enabled != nil && *file.Manifest.Enabled {
+		manifestDefault = "true"
+	}
+	manifestStr := getEnvOrDefault("KUBE_SENTRY_ATTACH_MANIFEST", manifestDefault)
+	if manifestStr == "true" || manifestStr == "1" {
+		if reasons := os.Getenv("KUBE_SENTRY_MANIFEST_REASONS"); reasons != "" {
+			cfg.ManifestReasons = splitAndTrim(reasons)
+		} else if file.Manifest.Reasons != nil {
+			cfg.ManifestReasons = file.Manifest.Reasons
+		} else {
+			cfg.ManifestReasons = []string{"FailedScheduling", "FailedMount", "FailedAttachVolume"}
+		}
+	}
+
+	// Parse previous container logs (default: off; rules opt reasons in)
+	if reasons := os.Getenv("KUBE_SENTRY_PREVIOUS_LOGS_REASONS"); reasons != "" {
+		cfg.PreviousLogsReasons = splitAndTrim(reasons)
+	}
+	cfg.PreviousLogsNamespaces = file.PreviousLogs.Namespaces
+	if ns := os.Getenv("KUBE_SENTRY_PREVIOUS_LOGS_NAMESPACES"); ns != "" {
+		cfg.PreviousLogsNamespaces = splitAndTrim(ns)
+	}
+	if len(cfg.PreviousLogsReasons) > 0 && len(cfg.PreviousLogsNamespaces) == 0 {
+		return nil, fmt.Errorf("previous logs are enabled for %v but no namespaces are allowed: set previousLogs.namespaces", cfg.PreviousLogsReasons)
+	}
+
+	for _, budget := range []struct {
+		env   string
+		value int64
+		def   int64
+		dest  *int64
+	}{
+		{"KUBE_SENTRY_PREVIOUS_LOGS_TAIL_LINES", file.PreviousLogs.TailLines, 100, &cfg.PreviousLogsTailLines},
+		{"KUBE_SENTRY_PREVIOUS_LOGS_MAX_BYTES", file.PreviousLogs.MaxBytes, 64 * 1024, &cfg.PreviousLogsMaxBytes},
+	} {
+		*budget.dest = budget.def
+		if budget.value != 0 {
+			*budget.dest = budget.value
+		}
+		if s := os.Getenv(budget.env); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: expected integer", budget.env, s)
+			}
+			*budget.dest = n
+		}
+		if *budget.dest <= 0 {
+			return nil, fmt.Errorf("invalid %s %d: must be positive", budget.env, *budget.dest)
+		}
+	}
+
+	logsTimeoutStr := getEnvOrDefault("KUBE_SENTRY_PREVIOUS_LOGS_TIMEOUT", orDefault(file.PreviousLogs.Timeout, "3s"))
+	logsTimeout, err := time.ParseDuration(logsTimeoutStr)
+	if err != nil || logsTimeout <= 0 {
+		return nil, fmt.Errorf("invalid KUBE_SENTRY_PREVIOUS_LOGS_TIMEOUT %q: must be a positive duration", logsTimeoutStr)
+	}
+	cfg.PreviousLogsTimeout = logsTimeout
+
+	// Parse liveness idle period
+	maxIdleStr := getEnvOrDefault("KUBE_SENTRY_HEALTH_MAX_IDLE", "10m")
+	maxIdle, err := time.ParseDuration(maxIdleStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KUBE_SENTRY_HEALTH_MAX_IDLE: %w", err)
+	}
+	cfg.HealthMaxIdle = maxIdle
+
+	// Parse leader election (default: disabled, single replica)
+	leaderElectStr := getEnvOrDefault("KUBE_SENTRY_LEADER_ELECT", "false")
+	cfg.LeaderElection = leaderElectStr == "true" || leaderElectStr == "1"
+	cfg.LeaseName = getEnvOrDefault("KUBE_SENTRY_LEASE_NAME", "kube-sentry-events")
+	cfg.LeaseNamespace = getEnvOrDefault("KUBE_SENTRY_LEASE_NAMESPACE", getEnvOrDefault("POD_NAMESPACE", "default"))
+	cfg.Identity = os.Getenv("POD_NAME")
+	if cfg.Identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine leader election identity: %w", err)
+		}
+		cfg.Identity = hostname
+	}
+
 	return cfg, nil
 }
 
@@ -149,6 +539,13 @@ func parseThreshold(s string) (int32, error) {
 	return result, nil
 }
 
+func orDefault(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -35,6 +35,8 @@ func TestLoad_DefaultValues(t *testing.T) {
 	t.Setenv("KUBE_SENTRY_EVENTS", "")
 	t.Setenv("KUBE_SENTRY_DEDUP_WINDOW", "")
 	t.Setenv("KUBE_SENTRY_LOG_LEVEL", "")
+	t.Setenv("KUBE_SENTRY_HEALTH_ADDR", "")
+	t.Setenv("KUBE_SENTRY_HEALTH_MAX_IDLE", "")
 
 	cfg, err := Load(false)
 	if err != nil {
@@ -53,6 +55,33 @@ func TestLoad_DefaultValues(t *testing.T) {
 		t.Errorf("expected default dedup window 5m, got %v", cfg.DedupWindow)
 	}
 
+	if cfg.DedupMaxEntries != 10000 {
+		t.Errorf("expected default dedup max entries 10000, got %d", cfg.DedupMaxEntries)
+	}
+
+	if cfg.FlappingThreshold != 3 || cfg.FlappingPeriod != time.Hour || cfg.FlappingEscalate {
+		t.Errorf("expected default flapping 3 in 1h without escalation, got %d in %v (escalate %v)",
+			cfg.FlappingThreshold, cfg.FlappingPeriod, cfg.FlappingEscalate)
+	}
+
+	if cfg.RenotifyInterval != 0 {
+		t.Errorf("expected reminders disabled by default, got %v", cfg.RenotifyInterval)
+	}
+
+	if len(cfg.ManifestReasons) != 0 {
+		t.Errorf("expected manifests off by default, got %v", cfg.ManifestReasons)
+	}
+
+	if len(cfg.PreviousLogsReasons) != 0 || cfg.PreviousLogsTailLines != 100 || cfg.PreviousLogsTimeout != 3*time.Second {
+		t.Errorf("expected previous logs off with 100 lines in 3s, got %v, %d lines in %v",
+			cfg.PreviousLogsReasons, cfg.PreviousLogsTailLines, cfg.PreviousLogsTimeout)
+	}
+
+	if cfg.AggregationWindow != 0 || cfg.AggregationMinWorkloads != 5 || len(cfg.AggregationReasons) != 2 {
+		t.Errorf("expected aggregation off with 5 workloads for 2 reasons, got %d in %v for %v",
+			cfg.AggregationMinWorkloads, cfg.AggregationWindow, cfg.AggregationReasons)
+	}
+
 	if cfg.LogLevel != "info" {
 		t.Errorf("expected default log level 'info', got %s", cfg.LogLevel)
 	}
@@ -68,6 +97,14 @@ func TestLoad_DefaultValues(t *testing.T) {
 	if len(cfg.EventReasons) == 0 {
 		t.Error("expected default event reasons to be set")
 	}
+
+	if cfg.HealthAddr != ":8081" {
+		t.Errorf("expected default health addr ':8081', got %s", cfg.HealthAddr)
+	}
+
+	if cfg.HealthMaxIdle != 10*time.Minute {
+		t.Errorf("expected default health max idle 10m, got %v", cfg.HealthMaxIdle)
+	}
 }
 
 func TestLoad_CustomValues(t *testing.T) {
@@ -122,6 +159,17 @@ func TestLoad_InvalidDedupWindow(t *testing.T) {
 	}
 }
 
+func TestLoad_InvalidDedupMaxEntries(t *testing.T) {
+	t.Setenv("SENTRY_DSN", "https://test@sentry.io/123")
+
+	for _, value := range []string{"many", "0", "-5"} {
+		t.Setenv("KUBE_SENTRY_DEDUP_MAX_ENTRIES", value)
+		if _, err := Load(false); err == nil {
+			t.Errorf("expected error for dedup max entries %q", value)
+		}
+	}
+}
+
 func TestDefaultEventReasons(t *testing.T) {
 	reasons := DefaultEventReasons()
 
@@ -135,6 +183,7 @@ func TestDefaultEventReasons(t *testing.T) {
 		"CrashLoopBackOff": false,
 		"FailedScheduling": false,
 		"ImagePullBackOff": false,
+		"ContainerCrashed": false,
 	}
 
 	for _, r := range reasons {
@@ -149,3 +198,40 @@ func TestDefaultEventReasons(t *testing.T) {
 		}
 	}
 }
+
+func TestLoad_LeaderElection(t *testing.T) {
+	t.Setenv("SENTRY_DSN", "https://test@sentry.io/123")
+	t.Setenv("KUBE_SENTRY_LEADER_ELECT", "true")
+	t.Setenv("KUBE_SENTRY_LEASE_NAME", "")
+	t.Setenv("KUBE_SENTRY_LEASE_NAMESPACE", "")
+	t.Setenv("POD_NAMESPACE", "monitoring")
+	t.Setenv("POD_NAME", "kube-sentry-events-abc12")
+
+	cfg, err := Load(false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !cfg.LeaderElection {
+		t.Error("expected leader election to be enabled")
+	}
+	if cfg.LeaseName != "kube-sentry-events" {
+		t.Errorf("expected default lease name, got %s", cfg.LeaseName)
+	}
+	if cfg.LeaseNamespace != "monitoring" {
+		t.Errorf("expected lease namespace from POD_NAMESPACE, got %s", cfg.LeaseNamespace)
+	}
+	if cfg.Identity != "kube-sentry-events-abc12" {
+		t.Errorf("expected identity from POD_NAME, got %s", cfg.Identity)
+	}
+}
+
+func TestLoad_InvalidHealthMaxIdle(t *testing.T) {
+	t.Setenv("SENTRY_DSN", "https://test@sentry.io/123")
+	t.Setenv("KUBE_SENTRY_HEALTH_MAX_IDLE", "soon")
+
+	_, err := Load(false)
+	if err == nil {
+		t.Error("expected error for invalid health max idle")
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/template"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+// validSeverities are the Sentry levels accepted in rules.
+var validSeverities = []string{"debug", "info", "warning", "error", "fatal"}
+
+// Troubleshooting overrides the built-in troubleshooting guidance for a reason.
+// Empty fields keep the built-in value. Description, DebugCommands and
+// RunbookURL are Go templates, e.g. "kubectl logs {{.Pod}} -n {{.Namespace}}".
+type Troubleshooting struct {
+	Description   string   `json:"description,omitempty"`
+	LikelyCauses  []string `json:"likelyCauses,omitempty"`
+	DebugCommands []string `json:"debugCommands,omitempty"`
+	RunbookURL    string   `json:"runbookURL,omitempty"`
+}
+
+// Rule configures how events with a given reason are handled.
+type Rule struct {
+	// Enabled adds (true, the default) or removes (false) the reason from the monitored list
+	Enabled         *bool            `json:"enabled,omitempty"`
+	Threshold       *int32           `json:"threshold,omitempty"`
+	Severity        string           `json:"severity,omitempty"`
+	DedupWindow     string           `json:"dedupWindow,omitempty"`
+	Troubleshooting *Troubleshooting `json:"troubleshooting,omitempty"`
+	// AttachPreviousLogs attaches the crashed container's logs to issues in
+	// the namespaces listed under previousLogs
+	AttachPreviousLogs bool `json:"attachPreviousLogs,omitempty"`
+}
+
+// SentryRoute sends events from matching namespaces to a separate Sentry DSN.
+type SentryRoute struct {
+	// Name identifies the route; workloads select it with the dsn-project annotation
+	Name              string   `json:"name"`
+	DSN               string   `json:"dsn"`
+	Namespaces        []string `json:"namespaces,omitempty"`
+	NamespaceSelector string   `json:"namespaceSelector,omitempty"`
+}
+
+// fileConfig is the layout of the --config YAML file.
+type fileConfig struct {
+	Sentry struct {
+		DSN         string `json:"dsn,omitempty"`
+		Environment string `json:"environment,omitempty"`
+		EnableLogs  *bool  `json:"enableLogs,omitempty"`
+		// Routes are evaluated in order; unmatched events go to DSN
+		Routes []SentryRoute `json:"routes,omitempty"`
+		// URL and Org locate the Web API used to resolve issues; the auth
+		// token is only read from SENTRY_AUTH_TOKEN
+		URL string `json:"url,omitempty"`
+		Org string `json:"org,omitempty"`
+	} `json:"sentry,omitempty"`
+
+	AutoResolve struct {
+		Enabled  *bool  `json:"enabled,omitempty"`
+		Interval string `json:"interval,omitempty"`
+	} `json:"autoResolve,omitempty"`
+	// Flapping flags events whose dedup window keeps reopening
+	Flapping struct {
+		Threshold *int   `json:"threshold,omitempty"`
+		Period    string `json:"period,omitempty"`
+		Escalate  *bool  `json:"escalate,omitempty"`
+	} `json:"flapping,omitempty"`
+	// PreviousLogs budgets attaching container logs for rules that opt in
+	PreviousLogs struct {
+		Namespaces []string `json:"namespaces,omitempty"`
+		TailLines  int64    `json:"tailLines,omitempty"`
+		MaxBytes   int64    `json:"maxBytes,omitempty"`
+		Timeout    string   `json:"timeout,omitempty"`
+	} `json:"previousLogs,omitempty"`
+	// Manifest attaches the involved object's manifest to issues
+	Manifest struct {
+		Enabled *bool    `json:"enabled,omitempty"`
+		Reasons []string `json:"reasons,omitempty"`
+	} `json:"manifest,omitempty"`
+	// Aggregation sends bursts across many workloads as a single issue
+	Aggregation struct {
+		Window       string   `json:"window,omitempty"`
+		MinWorkloads int      `json:"minWorkloads,omitempty"`
+		Reasons      []string `json:"reasons,omitempty"`
+	} `json:"aggregation,omitempty"`
+	// NamespaceRunbooks are runbook URL templates per namespace, overriding
+	// the reason's runbook URL
+	NamespaceRunbooks map[string]string `json:"namespaceRunbooks,omitempty"`
+
+	StateConfigMap    string `json:"stateConfigMap,omitempty"`
+	StateDir          string `json:"stateDir,omitempty"`
+	StateSaveInterval string `json:"stateSaveInterval,omitempty"`
+
+	Namespaces        []string `json:"namespaces,omitempty"`
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+
+	NamespaceSelector        string `json:"namespaceSelector,omitempty"`
+	ExcludeNamespaceSelector string `json:"excludeNamespaceSelector,omitempty"`
+	ObjectSelector           string `json:"objectSelector,omitempty"`
+	ExcludeObjectSelector    string `json:"excludeObjectSelector,omitempty"`
+
+	// Reasons replaces the default reason list; rules can still add or remove reasons
+	Reasons          []string        `json:"reasons,omitempty"`
+	WatchPodStatus   *bool           `json:"watchPodStatus,omitempty"`
+	WatchNodes       *bool           `json:"watchNodes,omitempty"`
+	WatchJobs        *bool           `json:"watchJobs,omitempty"`
+	DedupWindow      string          `json:"dedupWindow,omitempty"`
+	DedupMaxEntries  int             `json:"dedupMaxEntries,omitempty"`
+	RenotifyInterval string          `json:"renotifyInterval,omitempty"`
+	LogLevel         string          `json:"logLevel,omitempty"`
+	Rules            map[string]Rule `json:"rules,omitempty"`
+}
+
+func readFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	file := &fileConfig{}
+	if err := yaml.UnmarshalStrict(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// validateRoutes checks that every route has a unique name, a DSN and a valid selector.
+func validateRoutes(routes []SentryRoute) error {
+	names := make(map[string]struct{}, len(routes))
+	for i, route := range routes {
+		if route.Name == "" {
+			return fmt.Errorf("sentry route %d: name is required", i)
+		}
+		if _, dup := names[route.Name]; dup {
+			return fmt.Errorf("sentry route %q: duplicate name", route.Name)
+		}
+		names[route.Name] = struct{}{}
+		if route.DSN == "" {
+			return fmt.Errorf("sentry route %q: dsn is required", route.Name)
+		}
+		if _, err := labels.Parse(route.NamespaceSelector); err != nil {
+			return fmt.Errorf("sentry route %q: invalid namespaceSelector: %w", route.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateTemplate checks that a troubleshooting template parses and only
+// refers to fields it's rendered with, e.g. rejecting a misspelt {{.Podd}}.
+func validateTemplate(text string) error {
+	tmpl, err := template.New("troubleshooting").Parse(text)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(io.Discard, sentry.TemplateData{})
+}
+
+// applyRules merges per-reason rules into the config.
+func applyRules(cfg *Config, rules map[string]Rule) error {
+	cfg.Severities = make(map[string]string)
+	cfg.DedupWindows = make(map[string]time.Duration)
+	cfg.Troubleshooting = make(map[string]Troubleshooting)
+
+	for reason, rule := range rules {
+		if rule.Enabled != nil && !*rule.Enabled {
+			cfg.EventReasons = slices.DeleteFunc(cfg.EventReasons, func(r string) bool { return r == reason })
+			continue
+		}
+		if !slices.Contains(cfg.EventReasons, reason) {
+			cfg.EventReasons = append(cfg.EventReasons, reason)
+		}
+
+		if rule.Threshold != nil {
+			if *rule.Threshold < 1 {
+				return fmt.Errorf("invalid threshold for %s: must be at least 1, got %d", reason, *rule.Threshold)
+			}
+			cfg.EventThresholds[reason] = *rule.Threshold
+		}
+
+		if rule.Severity != "" {
+			if !slices.Contains(validSeverities, rule.Severity) {
+				return fmt.Errorf("invalid severity for %s: %q (expected one of %v)", reason, rule.Severity, validSeverities)
+			}
+			cfg.Severities[reason] = rule.Severity
+		}
+
+		if rule.DedupWindow != "" {
+			window, err := time.ParseDuration(rule.DedupWindow)
+			if err != nil {
+				return fmt.Errorf("invalid dedupWindow for %s: %w", reason, err)
+			}
+			cfg.DedupWindows[reason] = window
+		}
+
+		if rule.Troubleshooting != nil {
+			t := rule.Troubleshooting
+			for _, text := range append([]string{t.Description, t.RunbookURL}, t.DebugCommands...) {
+				if err := validateTemplate(text); err != nil {
+					return fmt.Errorf("invalid troubleshooting for %s: %w", reason, err)
+				}
+			}
+			cfg.Troubleshooting[reason] = *t
+		}
+
+		if rule.AttachPreviousLogs {
+			cfg.PreviousLogsReasons = append(cfg.PreviousLogsReasons, reason)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,463 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+// clearEnv unsets env vars that would override the config file.
+func clearEnv(t *testing.T) {
+	for _, key := range []string{
+		"SENTRY_DSN", "SENTRY_ENVIRONMENT", "KUBE_SENTRY_NAMESPACES", "KUBE_SENTRY_EXCLUDE_NAMESPACES",
+		"KUBE_SENTRY_EVENTS", "KUBE_SENTRY_THRESHOLDS", "KUBE_SENTRY_ENABLE_LOGS",
+		"KUBE_SENTRY_DEDUP_WINDOW", "KUBE_SENTRY_LOG_LEVEL",
+		"KUBE_SENTRY_NAMESPACE_SELECTOR", "KUBE_SENTRY_EXCLUDE_NAMESPACE_SELECTOR",
+		"KUBE_SENTRY_OBJECT_SELECTOR", "KUBE_SENTRY_EXCLUDE_OBJECT_SELECTOR",
+		"KUBE_SENTRY_WATCH_POD_STATUS", "KUBE_SENTRY_WATCH_NODES", "KUBE_SENTRY_WATCH_JOBS",
+		"SENTRY_URL", "SENTRY_ORG", "SENTRY_AUTH_TOKEN", "KUBE_SENTRY_AUTO_RESOLVE",
+		"KUBE_SENTRY_AUTO_RESOLVE_INTERVAL", "KUBE_SENTRY_STATE_CONFIGMAP", "KUBE_SENTRY_STATE_NAMESPACE",
+		"KUBE_SENTRY_STATE_DIR", "KUBE_SENTRY_STATE_SAVE_INTERVAL", "POD_NAMESPACE",
+		"KUBE_SENTRY_DEDUP_MAX_ENTRIES", "KUBE_SENTRY_FLAPPING_THRESHOLD", "KUBE_SENTRY_FLAPPING_PERIOD",
+		"KUBE_SENTRY_FLAPPING_ESCALATE", "KUBE_SENTRY_RENOTIFY_INTERVAL", "KUBE_SENTRY_AGGREGATION_WINDOW",
+		"KUBE_SENTRY_AGGREGATION_MIN_WORKLOADS", "KUBE_SENTRY_AGGREGATION_REASONS",
+		"KUBE_SENTRY_PREVIOUS_LOGS_REASONS", "KUBE_SENTRY_PREVIOUS_LOGS_NAMESPACES",
+		"KUBE_SENTRY_PREVIOUS_LOGS_TAIL_LINES", "KUBE_SENTRY_PREVIOUS_LOGS_MAX_BYTES", "KUBE_SENTRY_PREVIOUS_LOGS_TIMEOUT",
+		"KUBE_SENTRY_ATTACH_MANIFEST", "KUBE_SENTRY_MANIFEST_REASONS",
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestLoadFile_Rules(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, `
+sentry:
+  dsn: https://file@sentry.io/1
+  environment: staging
+  enableLogs: false
+namespaces: [payments]
+dedupWindow: 10m
+renotifyInterval: 2h
+logLevel: debug
+rules:
+  Unhealthy:
+    threshold: 10
+    severity: error
+    dedupWindow: 30m
+  FailedSync:
+    severity: warning
+    troubleshooting:
+      description: Pod sync failed.
+      runbookURL: https://runbooks.example.com/failed-sync
+  Evicted:
+    enabled: false
+namespaceRunbooks:
+  payments: https://wiki.example.com/payments/{{.Reason}}
+`)
+
+	cfg, err := LoadFile(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.SentryDSN != "https://file@sentry.io/1" || cfg.SentryEnvironment != "staging" {
+		t.Errorf("expected sentry settings from file, got %s / %s", cfg.SentryDSN, cfg.SentryEnvironment)
+	}
+	if cfg.EnableLogs {
+		t.Error("expected enableLogs false from file")
+	}
+	if cfg.RenotifyInterval != 2*time.Hour {
+		t.Errorf("expected renotify interval 2h from file, got %v", cfg.RenotifyInterval)
+	}
+	if cfg.DedupWindow != 10*time.Minute || cfg.LogLevel != "debug" {
+		t.Errorf("expected top-level fields from file, got %v / %s", cfg.DedupWindow, cfg.LogLevel)
+	}
+	if len(cfg.Namespaces) != 1 || cfg.Namespaces[0] != "payments" {
+		t.Errorf("expected namespaces [payments], got %v", cfg.Namespaces)
+	}
+
+	if cfg.EventThresholds["Unhealthy"] != 10 {
+		t.Errorf("expected Unhealthy threshold 10, got %d", cfg.EventThresholds["Unhealthy"])
+	}
+	if cfg.EventThresholds["BackOff"] != 3 {
+		t.Errorf("expected default BackOff threshold to be kept, got %d", cfg.EventThresholds["BackOff"])
+	}
+	if cfg.Severities["Unhealthy"] != "error" {
+		t.Errorf("expected Unhealthy severity error, got %q", cfg.Severities["Unhealthy"])
+	}
+	if cfg.DedupWindows["Unhealthy"] != 30*time.Minute {
+		t.Errorf("expected Unhealthy dedup window 30m, got %v", cfg.DedupWindows["Unhealthy"])
+	}
+	if cfg.Troubleshooting["FailedSync"].RunbookURL != "https://runbooks.example.com/failed-sync" {
+		t.Errorf("expected FailedSync troubleshooting, got %+v", cfg.Troubleshooting["FailedSync"])
+	}
+
+	if cfg.NamespaceRunbooks["payments"] != "https://wiki.example.com/payments/{{.Reason}}" {
+		t.Errorf("expected payments runbook, got %v", cfg.NamespaceRunbooks)
+	}
+
+	if !slices.Contains(cfg.EventReasons, "FailedSync") {
+		t.Error("expected rule to add FailedSync to monitored reasons")
+	}
+	if slices.Contains(cfg.EventReasons, "Evicted") {
+		t.Error("expected disabled rule to remove Evicted from monitored reasons")
+	}
+}
+
+func TestLoadFile_EnvOverridesFile(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SENTRY_DSN", "https://env@sentry.io/2")
+	t.Setenv("KUBE_SENTRY_DEDUP_WINDOW", "1m")
+	t.Setenv("KUBE_SENTRY_THRESHOLDS", "Unhealthy:7")
+	path := writeConfigFile(t, `
+sentry:
+  dsn: https://file@sentry.io/1
+dedupWindow: 10m
+rules:
+  Unhealthy:
+    threshold: 10
+`)
+
+	cfg, err := LoadFile(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.SentryDSN != "https://env@sentry.io/2" {
+		t.Errorf("expected env DSN to win, got %s", cfg.SentryDSN)
+	}
+	if cfg.DedupWindow != time.Minute {
+		t.Errorf("expected env dedup window to win, got %v", cfg.DedupWindow)
+	}
+	if cfg.EventThresholds["Unhealthy"] != 7 {
+		t.Errorf("expected env threshold to win, got %d", cfg.EventThresholds["Unhealthy"])
+	}
+}
+
+func TestLoadFile_InvalidRules(t *testing.T) {
+	tests := map[string]string{
+		"severity":       "rules:\n  Unhealthy:\n    severity: critical\n",
+		"dedupWindow":    "rules:\n  Unhealthy:\n    dedupWindow: soon\n",
+		"threshold":      "rules:\n  Unhealthy:\n    threshold: 0\n",
+		"unknown key":    "rules:\n  Unhealthy:\n    treshold: 5\n",
+		"template":       "rules:\n  Unhealthy:\n    troubleshooting:\n      debugCommands: ['kubectl logs {{.Pod']\n",
+		"runbook":        "namespaceRunbooks:\n  payments: https://wiki.example.com/{{.Reason\n",
+		"template field": "rules:\n  Unhealthy:\n    troubleshooting:\n      debugCommands: ['kubectl logs {{.Podd}}']\n",
+		"runbook field":  "namespaceRunbooks:\n  payments: https://wiki.example.com/{{.Namespce}}\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			if _, err := LoadFile(writeConfigFile(t, content), true); err == nil {
+				t.Error("expected error for invalid rule")
+			}
+		})
+	}
+}
+
+func TestLoadFile_Flapping(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("KUBE_SENTRY_FLAPPING_PERIOD", "2h")
+	path := writeConfigFile(t, `
+flapping:
+  threshold: 4
+  period: 30m
+  escalate: true
+`)
+
+	cfg, err := LoadFile(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.FlappingThreshold != 4 || !cfg.FlappingEscalate {
+		t.Errorf("expected flapping settings from file, got threshold %d escalate %v", cfg.FlappingThreshold, cfg.FlappingEscalate)
+	}
+	if cfg.FlappingPeriod != 2*time.Hour {
+		t.Errorf("expected env flapping period to win, got %v", cfg.FlappingPeriod)
+	}
+
+	// Zero disables flapping detection
+	cfg, err = LoadFile(writeConfigFile(t, "flapping:\n  threshold: 0\n"), true)
+	if err != nil || cfg.FlappingThreshold != 0 {
+		t.Errorf("expected flapping to be disabled, got threshold %d (%v)", cfg.FlappingThreshold, err)
+	}
+}
+
+func TestLoadFile_Aggregation(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("KUBE_SENTRY_AGGREGATION_MIN_WORKLOADS", "10")
+	path := writeConfigFile(t, `
+aggregation:
+  window: 30s
+  minWorkloads: 3
+  reasons: [Evicted, NodeNotReady]
+`)
+
+	cfg, err := LoadFile(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.AggregationWindow != 30*time.Second || !slices.Equal(cfg.AggregationReasons, []string{"Evicted", "NodeNotReady"}) {
+		t.Errorf("expected aggregation settings from file, got %v / %v", cfg.AggregationWindow, cfg.AggregationReasons)
+	}
+	if cfg.AggregationMinWorkloads != 10 {
+		t.Errorf("expected env min workloads to win, got %d", cfg.AggregationMinWorkloads)
+	}
+
+	// Zero disables aggregation
+	cfg, err = LoadFile(writeConfigFile(t, "aggregation:\n  window: 0s\n"), true)
+	if err != nil || cfg.AggregationWindow != 0 {
+		t.Errorf("expected aggregation to be disabled, got window %v (%v)", cfg.AggregationWindow, err)
+	}
+
+	t.Setenv("KUBE_SENTRY_AGGREGATION_MIN_WORKLOADS", "")
+	if _, err := LoadFile(writeConfigFile(t, "aggregation:\n  minWorkloads: 1\n"), true); err == nil {
+		t.Error("expected error for min workloads below 2")
+	}
+}
+
+func TestLoadFile_PreviousLogs(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("KUBE_SENTRY_PREVIOUS_LOGS_TIMEOUT", "1s")
+	path := writeConfigFile(t, `
+previousLogs:
+  namespaces: [payments]
+  tailLines: 50
+rules:
+  CrashLoopBackOff:
+    attachPreviousLogs: true
+  Unhealthy:
+    threshold: 10
+`)
+
+	cfg, err := LoadFile(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.PreviousLogsReasons, []string{"CrashLoopBackOff"}) || !slices.Equal(cfg.PreviousLogsNamespaces, []string{"payments"}) {
+		t.Errorf("expected logs for CrashLoopBackOff in payments, got %v in %v", cfg.PreviousLogsReasons, cfg.PreviousLogsNamespaces)
+	}
+	if cfg.PreviousLogsTailLines != 50 || cfg.PreviousLogsMaxBytes != 64*1024 || cfg.PreviousLogsTimeout != time.Second {
+		t.Errorf("unexpected budgets: %d lines, %d bytes, %v", cfg.PreviousLogsTailLines, cfg.PreviousLogsMaxBytes, cfg.PreviousLogsTimeout)
+	}
+
+	// Opting a reason in without an allowlist is an error
+	if _, err := LoadFile(writeConfigFile(t, "rules:\n  OOMKilled:\n    attachPreviousLogs: true\n"), true); err == nil {
+		t.Error("expected error for previous logs without namespaces")
+	}
+}
+
+func TestLoadFile_Manifest(t *testing.T) {
+	clearEnv(t)
+	cfg, err := LoadFile(writeConfigFile(t, "manifest:\n  enabled: true\n  reasons: [FailedMount, FailedBinding]\n"), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.ManifestReasons, []string{"FailedMount", "FailedBinding"}) {
+		t.Errorf("expected manifest reasons from file, got %v", cfg.ManifestReasons)
+	}
+
+	// Manifests are opt-in, even if reasons are listed
+	cfg, err = LoadFile(writeConfigFile(t, "manifest:\n  reasons: [FailedMount]\n"), true)
+	if err != nil || len(cfg.ManifestReasons) != 0 {
+		t.Errorf("expected manifests to be disabled, got %v (%v)", cfg.ManifestReasons, err)
+	}
+
+	t.Setenv("KUBE_SENTRY_ATTACH_MANIFEST", "true")
+	t.Setenv("KUBE_SENTRY_MANIFEST_REASONS", "FailedScheduling")
+	cfg, err = LoadFile(writeConfigFile(t, "manifest:\n  enabled: false\n"), true)
+	if err != nil || !slices.Equal(cfg.ManifestReasons, []string{"FailedScheduling"}) {
+		t.Errorf("expected env to enable manifests for FailedScheduling, got %v (%v)", cfg.ManifestReasons, err)
+	}
+}
+
+func TestLoadFile_MissingFile(t *testing.T) {
+	clearEnv(t)
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"), true); err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
+
+func TestLoadFile_Selectors(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("KUBE_SENTRY_EXCLUDE_OBJECT_SELECTOR", "tier=batch")
+	path := writeConfigFile(t, `
+namespaceSelector: env in (prod, staging)
+objectSelector: team=payments
+excludeObjectSelector: tier=cache
+`)
+
+	cfg, err := LoadFile(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.NamespaceSelector != "env in (prod, staging)" {
+		t.Errorf("unexpected namespace selector %q", cfg.NamespaceSelector)
+	}
+	if cfg.ObjectSelector != "team=payments" {
+		t.Errorf("unexpected object selector %q", cfg.ObjectSelector)
+	}
+	if cfg.ExcludeObjectSelector != "tier=batch" {
+		t.Errorf("expected env exclude selector to win, got %q", cfg.ExcludeObjectSelector)
+	}
+}
+
+func TestLoadFile_InvalidSelector(t *testing.T) {
+	clearEnv(t)
+	if _, err := LoadFile(writeConfigFile(t, "namespaceSelector: \"env in prod\"\n"), true); err == nil {
+		t.Error("expected error for invalid selector")
+	}
+}
+
+func TestLoadFile_SentryRoutes(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, `
+sentry:
+  dsn: https://default@sentry.io/1
+  routes:
+    - name: payments
+      dsn: https://payments@sentry.io/2
+      namespaces: [payments, billing]
+    - name: data
+      dsn: https://data@sentry.io/3
+      namespaceSelector: team=data
+`)
+
+	cfg, err := LoadFile(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.SentryRoutes) != 2 || cfg.SentryRoutes[1].NamespaceSelector != "team=data" {
+		t.Errorf("unexpected routes %+v", cfg.SentryRoutes)
+	}
+}
+
+func TestLoadFile_InvalidSentryRoutes(t *testing.T) {
+	tests := map[string]string{
+		"missing name": "sentry:\n  routes:\n    - dsn: https://a@sentry.io/1\n",
+		"missing dsn":  "sentry:\n  routes:\n    - name: a\n",
+		"duplicate":    "sentry:\n  routes:\n    - {name: a, dsn: https://a@sentry.io/1}\n    - {name: a, dsn: https://b@sentry.io/2}\n",
+		"selector":     "sentry:\n  routes:\n    - {name: a, dsn: https://a@sentry.io/1, namespaceSelector: \"team in a\"}\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			if _, err := LoadFile(writeConfigFile(t, content), true); err == nil {
+				t.Error("expected error for invalid route")
+			}
+		})
+	}
+}
+
+func TestLoadFile_WatchPodStatusNodesAndJobs(t *testing.T) {
+	clearEnv(t)
+	cfg, err := LoadFile("", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.WatchPodStatus || !cfg.WatchNodes || !cfg.WatchJobs {
+		t.Error("expected pod status, node and job watching to be enabled by default")
+	}
+
+	cfg, err = LoadFile(writeConfigFile(t, "watchPodStatus: false\nwatchNodes: false\nwatchJobs: false\n"), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.WatchPodStatus || cfg.WatchNodes || cfg.WatchJobs {
+		t.Error("expected config file to disable pod status, node and job watching")
+	}
+}
+
+func TestLoadFile_AutoResolve(t *testing.T) {
+	clearEnv(t)
+	cfg, err := LoadFile("", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.AutoResolve || cfg.AutoResolveInterval != time.Minute || cfg.StateConfigMap != "" || cfg.SentryAuthToken != "" {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+
+	t.Setenv("SENTRY_AUTH_TOKEN", "secret")
+	t.Setenv("POD_NAMESPACE", "monitoring")
+	path := writeConfigFile(t, `
+sentry:
+  url: https://sentry.example.com
+  org: acme
+autoResolve:
+  interval: 5m
+stateConfigMap: kube-sentry-events-state
+`)
+	cfg, err = LoadFile(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SentryURL != "https://sentry.example.com" || cfg.SentryOrg != "acme" || cfg.SentryAuthToken != "secret" {
+		t.Errorf("unexpected Sentry API config: %q %q %q", cfg.SentryURL, cfg.SentryOrg, cfg.SentryAuthToken)
+	}
+	if cfg.AutoResolveInterval != 5*time.Minute {
+		t.Errorf("expected interval 5m, got %v", cfg.AutoResolveInterval)
+	}
+	if cfg.StateConfigMap != "kube-sentry-events-state" || cfg.StateNamespace != "monitoring" {
+		t.Errorf("unexpected state ConfigMap %s/%s", cfg.StateNamespace, cfg.StateConfigMap)
+	}
+}
+
+func TestLoadFile_AutoResolveInvalid(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("SENTRY_AUTH_TOKEN", "secret")
+	if _, err := LoadFile("", true); err == nil {
+		t.Error("expected error for auth token without org")
+	}
+
+	t.Setenv("SENTRY_AUTH_TOKEN", "")
+	t.Setenv("KUBE_SENTRY_AUTO_RESOLVE_INTERVAL", "0s")
+	if _, err := LoadFile("", true); err == nil {
+		t.Error("expected error for zero interval")
+	}
+}
+
+func TestLoadFile_StateDir(t *testing.T) {
+	clearEnv(t)
+	cfg, err := LoadFile("", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StateDir != "" || cfg.StateSaveInterval != time.Minute {
+		t.Errorf("unexpected defaults: dir %q, interval %v", cfg.StateDir, cfg.StateSaveInterval)
+	}
+
+	cfg, err = LoadFile(writeConfigFile(t, "stateDir: /var/lib/kube-sentry-events\nstateSaveInterval: 30s\n"), true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StateDir != "/var/lib/kube-sentry-events" || cfg.StateSaveInterval != 30*time.Second {
+		t.Errorf("unexpected state config: dir %q, interval %v", cfg.StateDir, cfg.StateSaveInterval)
+	}
+
+	t.Setenv("KUBE_SENTRY_STATE_CONFIGMAP", "kube-sentry-events-state")
+	if _, err := LoadFile(writeConfigFile(t, "stateDir: /data\n"), true); err == nil {
+		t.Error("expected error for both state ConfigMap and directory")
+	}
+
+	t.Setenv("KUBE_SENTRY_STATE_CONFIGMAP", "")
+	t.Setenv("KUBE_SENTRY_STATE_SAVE_INTERVAL", "-1s")
+	if _, err := LoadFile("", true); err == nil {
+		t.Error("expected error for negative save interval")
+	}
+}
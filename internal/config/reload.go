@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Reloader re-reads the config file when its contents change or when triggered
+// (e.g. by SIGHUP). ConfigMap volume updates swap a symlink, so the file is
+// polled by content hash rather than watched for writes.
+type Reloader struct {
+	path     string
+	dryRun   bool
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// NewReloader creates a reloader for the config file at path.
+func NewReloader(path string, dryRun bool, interval time.Duration, logger *slog.Logger) *Reloader {
+	return &Reloader{
+		path:     path,
+		dryRun:   dryRun,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run calls apply with the new config whenever the file changes or trigger fires.
+// Invalid configs are logged and skipped, keeping the previous config in effect.
+// It blocks until the context is cancelled.
+func (r *Reloader) Run(ctx context.Context, trigger <-chan os.Signal, apply func(*Config)) {
+	lastHash := r.hash()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hash := r.hash()
+			if hash == nil || bytes.Equal(hash, lastHash) {
+				continue
+			}
+			lastHash = hash
+			r.logger.Info("config file changed, reloading", "path", r.path)
+		case sig := <-trigger:
+			lastHash = r.hash()
+			r.logger.Info("received signal, reloading config", "signal", sig, "path", r.path)
+		}
+
+		cfg, err := LoadFile(r.path, r.dryRun)
+		if err != nil {
+			r.logger.Error("invalid config, keeping previous config", "path", r.path, "error", err)
+			continue
+		}
+		apply(cfg)
+	}
+}
+
+// hash returns the SHA-256 of the file contents, or nil if it can't be read
+// (e.g. mid-way through a ConfigMap update).
+func (r *Reloader) hash() []byte {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
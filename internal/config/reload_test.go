@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startReloader runs a reloader in the background and returns a channel of applied configs.
+func startReloader(t *testing.T, path string, trigger chan os.Signal) <-chan *Config {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	applied := make(chan *Config, 10)
+	r := NewReloader(path, true, 10*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go r.Run(ctx, trigger, func(cfg *Config) {
+		applied <- cfg
+	})
+	return applied
+}
+
+func expectReload(t *testing.T, applied <-chan *Config) *Config {
+	t.Helper()
+	select {
+	case cfg := <-applied:
+		return cfg
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected config to be reloaded")
+		return nil
+	}
+}
+
+func expectNoReload(t *testing.T, applied <-chan *Config) {
+	t.Helper()
+	select {
+	case cfg := <-applied:
+		t.Fatalf("expected no reload, got %+v", cfg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReloader_ReloadsOnFileChange(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "rules:\n  Unhealthy:\n    threshold: 5\n")
+	applied := startReloader(t, path, nil)
+
+	// Let the reloader record the initial hash
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("rules:\n  Unhealthy:\n    threshold: 20\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := expectReload(t, applied)
+	if cfg.EventThresholds["Unhealthy"] != 20 {
+		t.Errorf("expected reloaded threshold 20, got %d", cfg.EventThresholds["Unhealthy"])
+	}
+}
+
+func TestReloader_KeepsPreviousConfigOnError(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "rules:\n  Unhealthy:\n    threshold: 5\n")
+	applied := startReloader(t, path, nil)
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("rules:\n  Unhealthy:\n    severity: catastrophic\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	expectNoReload(t, applied)
+}
+
+func TestReloader_ReloadsOnTrigger(t *testing.T) {
+	clearEnv(t)
+	path := writeConfigFile(t, "rules:\n  Unhealthy:\n    threshold: 5\n")
+	trigger := make(chan os.Signal, 1)
+	applied := startReloader(t, path, trigger)
+
+	trigger <- syscall.SIGHUP
+
+	cfg := expectReload(t, applied)
+	if cfg.EventThresholds["Unhealthy"] != 5 {
+		t.Errorf("expected threshold 5, got %d", cfg.EventThresholds["Unhealthy"])
+	}
+}
@@ -0,0 +1,203 @@
+// Package correlate aggregates bursts of events, e.g. from a node failure, into
+// a single Sentry issue.
+package correlate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+// Sender sends events on to Sentry or dry-run output.
+type Sender interface {
+	Send(data sentry.EventData)
+}
+
+// group buffers the issues for one reason on one node or namespace.
+type group struct {
+	reason    string
+	node      string // empty if grouped by namespace
+	namespace string // empty if grouped by node
+	events    []sentry.EventData
+	workloads map[string]struct{}
+	timer     clock.Timer
+}
+
+// Correlator buffers issues for the configured reasons for a window. If at
+// least minWorkloads distinct workloads hit the same reason on the same node
+// (or, for events without a node, in the same namespace) within it, they're
+// sent as logs only plus a single aggregated issue; otherwise they're sent
+// unchanged. Everything else passes straight through.
+type Correlator struct {
+	next         Sender
+	window       time.Duration
+	minWorkloads int
+	reasons      map[string]struct{}
+	clock        clock.WithDelayedExecution
+
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+// New creates a correlator sending to next.
+func New(next Sender, window time.Duration, minWorkloads int, reasons []string) *Correlator {
+	return NewWithClock(next, window, minWorkloads, reasons, clock.RealClock{})
+}
+
+// NewWithClock is like New but times windows with clk.
+func NewWithClock(next Sender, window time.Duration, minWorkloads int, reasons []string, clk clock.WithDelayedExecution) *Correlator {
+	c := &Correlator{
+		next:         next,
+		window:       window,
+		minWorkloads: minWorkloads,
+		reasons:      make(map[string]struct{}, len(reasons)),
+		clock:        clk,
+		groups:       make(map[string]*group),
+	}
+	for _, r := range reasons {
+		c.reasons[r] = struct{}{}
+	}
+	return c
+}
+
+// Send buffers issues for correlated reasons and passes anything else on.
+func (c *Correlator) Send(data sentry.EventData) {
+	if _, ok := c.reasons[data.Event.Reason]; !ok || !data.MeetsThreshold || data.Reminder || data.Resolved {
+		c.next.Send(data)
+		return
+	}
+
+	event := data.Event
+	namespace := event.InvolvedObject.Namespace
+	if namespace == "" {
+		namespace = event.Namespace
+	}
+	g := &group{reason: event.Reason, node: event.Source.Host}
+	if g.node == "" {
+		g.namespace = namespace
+	}
+	key := g.reason + "/" + g.node + "/" + g.namespace
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if existing, ok := c.groups[key]; ok {
+		g = existing
+	} else {
+		g.workloads = make(map[string]struct{})
+		g.timer = c.clock.AfterFunc(c.window, func() { c.flush(key) })
+		c.groups[key] = g
+	}
+	wl := sentry.GuessWorkload(event.InvolvedObject)
+	if data.Workload.Name != "" {
+		wl = data.Workload
+	}
+	g.events = append(g.events, data)
+	g.workloads[namespace+"/"+wl.Kind+"/"+wl.Name] = struct{}{}
+}
+
+// Close sends everything still buffered, e.g. on shutdown.
+func (c *Correlator) Close() {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.groups))
+	for key, g := range c.groups {
+		g.timer.Stop()
+		keys = append(keys, key)
+	}
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		c.flush(key)
+	}
+}
+
+// flush sends a group's events once its window is over.
+func (c *Correlator) flush(key string) {
+	c.mu.Lock()
+	g, ok := c.groups[key]
+	delete(c.groups, key)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if len(g.workloads) < c.minWorkloads {
+		for _, data := range g.events {
+			c.next.Send(data)
+		}
+		return
+	}
+
+	for _, data := range g.events {
+		data.MeetsThreshold = false
+		c.next.Send(data)
+	}
+	c.next.Send(g.aggregate())
+	metrics.BurstsAggregated.Inc()
+}
+
+// aggregate returns the single issue summarising the group.
+func (g *group) aggregate() sentry.EventData {
+	workloads := make([]string, 0, len(g.workloads))
+	for wl := range g.workloads {
+		workloads = append(workloads, wl)
+	}
+	sort.Strings(workloads)
+
+	first, last := g.events[0], g.events[len(g.events)-1]
+	wl := workload.Workload{Kind: "Node", Name: g.node}
+	where := "node " + g.node
+	if g.node == "" {
+		wl = workload.Workload{Kind: "Namespace", Name: g.namespace}
+		where = "namespace " + g.namespace
+	}
+	now := last.LastSeen
+
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: g.namespace,
+			Name:      fmt.Sprintf("%s.%s.%d", strings.ToLower(wl.Kind), wl.Name, now.Unix()),
+			UID:       types.UID(fmt.Sprintf("aggregate/%s/%s/%s/%d", g.reason, g.node, g.namespace, now.UnixNano())),
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      wl.Kind,
+			Namespace: g.namespace,
+			Name:      wl.Name,
+		},
+		Reason:         g.reason,
+		Message:        fmt.Sprintf("%d workloads hit %s on %s: %s", len(workloads), g.reason, where, strings.Join(workloads, ", ")),
+		Type:           corev1.EventTypeWarning,
+		Count:          int32(len(g.events)),
+		Source:         corev1.EventSource{Component: "kube-sentry-events", Host: g.node},
+		FirstTimestamp: metav1.NewTime(first.FirstSeen),
+		LastTimestamp:  metav1.NewTime(now),
+	}
+
+	return sentry.EventData{
+		Event:           event,
+		Severity:        first.Severity,
+		Count:           len(g.events),
+		FirstSeen:       first.FirstSeen,
+		LastSeen:        now,
+		MeetsThreshold:  true,
+		Workload:        wl,
+		Project:         first.Project,
+		NamespaceLabels: first.NamespaceLabels,
+		Aggregate: &sentry.Aggregate{
+			Node:      g.node,
+			Namespace: g.namespace,
+			Workloads: workloads,
+			Events:    len(g.events),
+		},
+	}
+}
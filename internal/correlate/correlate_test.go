@@ -0,0 +1,157 @@
+package correlate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+type recordingSender struct {
+	sent []sentry.EventData
+}
+
+func (r *recordingSender) Send(data sentry.EventData) {
+	r.sent = append(r.sent, data)
+}
+
+func newTestCorrelator(t *testing.T, minWorkloads int) (*Correlator, *recordingSender, *clocktesting.FakeClock) {
+	t.Helper()
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	next := &recordingSender{}
+	return NewWithClock(next, 10*time.Second, minWorkloads, []string{"Evicted", "FailedScheduling"}, clk), next, clk
+}
+
+func eventData(namespace, pod, reason, node string) sentry.EventData {
+	return sentry.EventData{
+		Event: &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: pod + ".1"},
+			InvolvedObject: corev1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: namespace,
+				Name:      pod,
+			},
+			Reason: reason,
+			Type:   corev1.EventTypeWarning,
+			Source: corev1.EventSource{Host: node},
+		},
+		Severity:       "error",
+		Count:          1,
+		MeetsThreshold: true,
+	}
+}
+
+func TestCorrelator_AggregatesBurstOnNode(t *testing.T) {
+	c, next, clk := newTestCorrelator(t, 3)
+
+	for i := range 4 {
+		c.Send(eventData("default", fmt.Sprintf("api%d-5d8f7b9c4-x2k9p", i), "Evicted", "node-1"))
+	}
+	// Two pods of the same deployment count as one workload
+	c.Send(eventData("default", "api0-5d8f7b9c4-q7w3z", "Evicted", "node-1"))
+
+	if len(next.sent) != 0 {
+		t.Fatalf("expected events to be buffered, got %d sent", len(next.sent))
+	}
+
+	clk.Step(10 * time.Second)
+
+	if len(next.sent) != 6 {
+		t.Fatalf("expected 5 logs and 1 aggregate, got %d", len(next.sent))
+	}
+	for _, data := range next.sent[:5] {
+		if data.MeetsThreshold {
+			t.Errorf("expected individual event %s to be sent as a log only", data.Event.InvolvedObject.Name)
+		}
+	}
+
+	agg := next.sent[5]
+	if agg.Aggregate == nil || !agg.MeetsThreshold {
+		t.Fatalf("expected an aggregated issue, got %+v", agg)
+	}
+	if agg.Aggregate.Node != "node-1" || len(agg.Aggregate.Workloads) != 4 || agg.Aggregate.Events != 5 {
+		t.Errorf("unexpected aggregate: %+v", agg.Aggregate)
+	}
+	if agg.Event.InvolvedObject.Kind != "Node" || agg.Event.InvolvedObject.Name != "node-1" {
+		t.Errorf("expected aggregate to involve node-1, got %+v", agg.Event.InvolvedObject)
+	}
+	if agg.Workload.Kind != "Node" || agg.Severity != "error" {
+		t.Errorf("unexpected aggregate workload %+v / severity %s", agg.Workload, agg.Severity)
+	}
+}
+
+func TestCorrelator_BelowMinWorkloadsSendsUnchanged(t *testing.T) {
+	c, next, clk := newTestCorrelator(t, 3)
+
+	c.Send(eventData("default", "api-abc12", "Evicted", "node-1"))
+	c.Send(eventData("default", "worker-abc12", "Evicted", "node-1"))
+	// Different node, so a separate group
+	c.Send(eventData("default", "web-abc12", "Evicted", "node-2"))
+
+	clk.Step(10 * time.Second)
+
+	if len(next.sent) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(next.sent))
+	}
+	for _, data := range next.sent {
+		if !data.MeetsThreshold || data.Aggregate != nil {
+			t.Errorf("expected %s to be sent unchanged", data.Event.InvolvedObject.Name)
+		}
+	}
+}
+
+func TestCorrelator_GroupsByNamespaceWithoutNode(t *testing.T) {
+	c, next, clk := newTestCorrelator(t, 2)
+
+	c.Send(eventData("payments", "api-abc12", "FailedScheduling", ""))
+	c.Send(eventData("payments", "worker-abc12", "FailedScheduling", ""))
+	c.Send(eventData("billing", "api-abc12", "FailedScheduling", ""))
+
+	clk.Step(10 * time.Second)
+
+	var aggregates []sentry.EventData
+	for _, data := range next.sent {
+		if data.Aggregate != nil {
+			aggregates = append(aggregates, data)
+		}
+	}
+	if len(aggregates) != 1 {
+		t.Fatalf("expected 1 aggregate, got %d", len(aggregates))
+	}
+	if aggregates[0].Aggregate.Namespace != "payments" || aggregates[0].Event.InvolvedObject.Kind != "Namespace" {
+		t.Errorf("expected aggregate for namespace payments, got %+v", aggregates[0].Aggregate)
+	}
+}
+
+func TestCorrelator_PassesThroughOtherEvents(t *testing.T) {
+	c, next, _ := newTestCorrelator(t, 2)
+
+	c.Send(eventData("default", "api-abc12", "OOMKilled", "node-1"))
+	below := eventData("default", "api-abc12", "Evicted", "node-1")
+	below.MeetsThreshold = false
+	c.Send(below)
+	reminder := eventData("default", "api-abc12", "Evicted", "node-1")
+	reminder.Reminder = true
+	c.Send(reminder)
+
+	if len(next.sent) != 3 {
+		t.Errorf("expected 3 events passed through immediately, got %d", len(next.sent))
+	}
+}
+
+func TestCorrelator_CloseFlushesPending(t *testing.T) {
+	c, next, _ := newTestCorrelator(t, 2)
+
+	c.Send(eventData("default", "api-abc12", "Evicted", "node-1"))
+	c.Send(eventData("default", "worker-abc12", "Evicted", "node-1"))
+	c.Close()
+
+	if len(next.sent) != 3 || next.sent[2].Aggregate == nil {
+		t.Errorf("expected Close to send 2 logs and 1 aggregate, got %d events", len(next.sent))
+	}
+}
@@ -1,13 +1,30 @@
 package dedup
 
 import (
+	"container/heap"
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
+
+	"k8s.io/utils/clock"
+
+	"github.com/imankulov/kube-sentry-events/internal/state"
 )
 
 const (
-	// MaxEntries is the maximum number of entries in the cache.
-	MaxEntries = 10000
+	// DefaultMaxEntries is the default maximum number of entries in the cache.
+	DefaultMaxEntries = 10000
+
+	// maxSnapshotEntries bounds a snapshot to well under the 1MiB ConfigMap
+	// limit; the most recently seen entries are kept.
+	maxSnapshotEntries = 5000
+
+	// cleanupInterval is how often expired entries are removed.
+	cleanupInterval = time.Minute
 )
 
 // entry represents a cached event.
@@ -17,52 +34,235 @@ type entry struct {
 	count     int
 	firstSeen time.Time
 	lastSeen  time.Time
+	// opened holds when recent windows opened, oldest first; only tracked
+	// while flapping detection is enabled
+	opened []time.Time
+	// notifiedAt is when the window opened or the last reminder was due
+	notifiedAt time.Time
+
+	elem  *list.Element // position in the LRU list
+	index int           // position in the expiry heap
+}
+
+// expiryHeap orders entries by expiresAt, soonest first. Windows differ per
+// reason, so this isn't the same order as the LRU list.
+type expiryHeap []*entry
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// touch records an occurrence at now and extends the window. isNew opens a
+// new window, which counts towards flapping.
+func (e *entry) touch(now time.Time, window time.Duration, isNew bool, flapThreshold int, flapPeriod time.Duration) {
+	e.count++
+	e.lastSeen = now
+	e.expiresAt = now.Add(window)
+	if !isNew || flapThreshold <= 0 {
+		return
+	}
+
+	e.opened = append(e.opened, now)
+	cutoff := now.Add(-flapPeriod)
+	for len(e.opened) > 0 && e.opened[0].Before(cutoff) {
+		e.opened = e.opened[1:]
+	}
+	if len(e.opened) > flapThreshold {
+		e.opened = e.opened[len(e.opened)-flapThreshold:]
+	}
+}
+
+// Occurrence describes an event as seen by the deduplicator.
+type Occurrence struct {
+	// IsNew is true if the event opens a new window and should be sent
+	IsNew     bool
+	Count     int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Recurrences is how many windows opened within the flapping period,
+	// including this one (zero if flapping detection is disabled)
+	Recurrences int
+	// Flapping is true once Recurrences reaches the flapping threshold
+	Flapping bool
+	// Remind is true for a duplicate that is due a reminder, because the
+	// event kept firing for the renotify interval since it was last sent
+	Remind bool
 }
 
 // Deduplicator prevents sending duplicate events within a time window.
+// Touching, evicting and expiring an entry are O(log n) at most.
 type Deduplicator struct {
-	mu      sync.Mutex
-	window  time.Duration
-	entries map[string]*entry
-	order   []string // LRU order tracking
+	mu         sync.Mutex
+	window     time.Duration
+	maxEntries int
+	entries    map[string]*entry
+	lru        *list.List // most recently seen first
+	expiry     expiryHeap
+
+	// Expired entries are kept for flapPeriod so a recurrence keeps its count
+	// and first seen; flapThreshold recurrences within it mark it flapping
+	flapThreshold int
+	flapPeriod    time.Duration
+
+	// renotify is how often an ongoing event is due a reminder; zero disables it
+	renotify time.Duration
+
+	store state.Store // nil keeps entries in memory only
+
+	clock     clock.WithTicker
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 // New creates a new deduplicator with the given time window.
 func New(window time.Duration) *Deduplicator {
+	return NewWithClock(window, clock.RealClock{})
+}
+
+// NewWithClock is like New but reads the time from clk, which also drives the
+// periodic cleanup. Call Close to stop the cleanup goroutine.
+func NewWithClock(window time.Duration, clk clock.WithTicker) *Deduplicator {
 	d := &Deduplicator{
-		window:  window,
-		entries: make(map[string]*entry),
-		order:   make([]string, 0),
+		window:     window,
+		maxEntries: DefaultMaxEntries,
+		entries:    make(map[string]*entry),
+		lru:        list.New(),
+		clock:      clk,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
 	}
-	go d.cleanupLoop()
+	// Created before the goroutine starts so a fake clock sees it right away
+	ticker := clk.NewTicker(cleanupInterval)
+	go d.cleanupLoop(ticker)
 	return d
 }
 
+// Close stops the cleanup goroutine and waits for it to exit. Entries stay
+// usable; they just aren't removed in the background anymore.
+func (d *Deduplicator) Close() {
+	d.closeOnce.Do(func() { close(d.stop) })
+	<-d.done
+}
+
+// SetMaxEntries limits the cache to n entries, evicting the least recently
+// seen ones beyond it. A non-positive n means DefaultMaxEntries.
+func (d *Deduplicator) SetMaxEntries(n int) {
+	if n <= 0 {
+		n = DefaultMaxEntries
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxEntries = n
+	for len(d.entries) > d.maxEntries {
+		d.remove(d.lru.Back().Value.(*entry))
+	}
+}
+
+// SetFlapping flags events whose window opens threshold times within period,
+// e.g. a crash that recurs just after each window expires. Expired entries are
+// kept for period, so such events keep their count and first seen across
+// windows. A non-positive threshold disables flapping detection.
+func (d *Deduplicator) SetFlapping(threshold int, period time.Duration) {
+	if threshold <= 0 || period <= 0 {
+		threshold, period = 0, 0
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flapThreshold = threshold
+	d.flapPeriod = period
+}
+
+// SetRenotifyInterval makes a duplicate due a reminder (Occurrence.Remind)
+// once interval has passed since the event was last sent, so a problem that
+// keeps firing is reported again. A non-positive interval disables reminders.
+func (d *Deduplicator) SetRenotifyInterval(interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.renotify = max(interval, 0)
+}
+
 // Check returns true if this is a new event (should be sent),
 // false if it's a duplicate (should be skipped).
 // Also returns the count of occurrences and first/last seen times.
 func (d *Deduplicator) Check(namespace, pod, reason string) (isNew bool, count int, firstSeen, lastSeen time.Time) {
+	return d.CheckWindow(namespace, pod, reason, 0)
+}
+
+// CheckWindow is like Check but uses the given window instead of the default.
+// A zero window means the deduplicator's default window.
+func (d *Deduplicator) CheckWindow(namespace, pod, reason string, window time.Duration) (isNew bool, count int, firstSeen, lastSeen time.Time) {
+	o := d.Observe(namespace, pod, reason, window)
+	return o.IsNew, o.Count, o.FirstSeen, o.LastSeen
+}
+
+// Observe is like CheckWindow but also reports recurrences and flapping.
+func (d *Deduplicator) Observe(namespace, pod, reason string, window time.Duration) Occurrence {
 	key := namespace + "/" + pod + "/" + reason
-	now := time.Now()
+	now := d.clock.Now()
+	if window <= 0 {
+		window = d.window
+	}
 
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if e, exists := d.entries[key]; exists {
-		if now.Before(e.expiresAt) {
-			// Still within window, increment count and update lastSeen
-			e.count++
-			e.lastSeen = now
-			e.expiresAt = now.Add(d.window) // Extend window
-			return false, e.count, e.firstSeen, e.lastSeen
+	e, exists := d.entries[key]
+	switch {
+	case exists && now.Before(e.expiresAt.Add(d.flapPeriod)):
+		// Within the window it's a duplicate; after it, a recurrence that
+		// keeps its history
+		isNew := !now.Before(e.expiresAt)
+		e.touch(now, window, isNew, d.flapThreshold, d.flapPeriod)
+		d.lru.MoveToFront(e.elem)
+		heap.Fix(&d.expiry, e.index)
+
+		remind := !isNew && d.renotify > 0 && !now.Before(e.notifiedAt.Add(d.renotify))
+		if isNew || remind {
+			e.notifiedAt = now
 		}
+		o := d.occurrence(e, isNew)
+		o.Remind = remind
+		return o
+	case exists:
 		// Expired, treat as new
-		delete(d.entries, key)
+		d.remove(e)
 	}
 
 	// New entry
-	d.addEntry(key, now)
-	return true, 1, now, now
+	return d.occurrence(d.addEntry(key, now, window), true)
+}
+
+func (d *Deduplicator) occurrence(e *entry, isNew bool) Occurrence {
+	return Occurrence{
+		IsNew:       isNew,
+		Count:       e.count,
+		FirstSeen:   e.firstSeen,
+		LastSeen:    e.lastSeen,
+		Recurrences: len(e.opened),
+		Flapping:    d.flapThreshold > 0 && len(e.opened) >= d.flapThreshold,
+	}
 }
 
 // GetStats returns the count and timestamps for an event without marking it.
@@ -72,58 +272,85 @@ func (d *Deduplicator) GetStats(namespace, pod, reason string) (count int, first
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	if e, ok := d.entries[key]; ok && time.Now().Before(e.expiresAt) {
+	if e, ok := d.entries[key]; ok && d.clock.Now().Before(e.expiresAt) {
 		return e.count, e.firstSeen, e.lastSeen, true
 	}
 	return 0, time.Time{}, time.Time{}, false
 }
 
-func (d *Deduplicator) addEntry(key string, now time.Time) {
-	// Evict oldest if at capacity
-	for len(d.entries) >= MaxEntries && len(d.order) > 0 {
-		oldest := d.order[0]
-		d.order = d.order[1:]
-		delete(d.entries, oldest)
+// Forget expires an event, so its next occurrence is new again. This is used
+// when the problem is known to have cleared. With flapping detection enabled
+// its history is kept, since recovering and failing again is flapping.
+func (d *Deduplicator) Forget(namespace, pod, reason string) {
+	key := namespace + "/" + pod + "/" + reason
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[key]
+	switch {
+	case !ok:
+	case d.flapPeriod > 0:
+		e.expiresAt = d.clock.Now()
+		heap.Fix(&d.expiry, e.index)
+	default:
+		d.remove(e)
+	}
+}
+
+func (d *Deduplicator) addEntry(key string, now time.Time, window time.Duration) *entry {
+	// Evict the least recently seen if at capacity
+	for len(d.entries) >= d.maxEntries && d.lru.Len() > 0 {
+		d.remove(d.lru.Back().Value.(*entry))
 	}
 
 	e := &entry{
-		key:       key,
-		expiresAt: now.Add(d.window),
-		count:     1,
-		firstSeen: now,
-		lastSeen:  now,
+		key:        key,
+		expiresAt:  now.Add(window),
+		count:      1,
+		firstSeen:  now,
+		lastSeen:   now,
+		notifiedAt: now,
+	}
+	if d.flapThreshold > 0 {
+		e.opened = []time.Time{now}
 	}
+	e.elem = d.lru.PushFront(e)
+	heap.Push(&d.expiry, e)
 	d.entries[key] = e
-	d.order = append(d.order, key)
+	return e
+}
+
+// remove drops e from the map, the LRU list and the expiry heap.
+func (d *Deduplicator) remove(e *entry) {
+	delete(d.entries, e.key)
+	d.lru.Remove(e.elem)
+	heap.Remove(&d.expiry, e.index)
 }
 
-func (d *Deduplicator) cleanupLoop() {
-	ticker := time.NewTicker(time.Minute)
+func (d *Deduplicator) cleanupLoop(ticker clock.Ticker) {
+	defer close(d.done)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		d.cleanup()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C():
+			d.cleanup()
+		}
 	}
 }
 
 func (d *Deduplicator) cleanup() {
-	now := time.Now()
+	now := d.clock.Now()
 
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Remove expired entries
-	newOrder := make([]string, 0, len(d.order))
-	for _, key := range d.order {
-		if e, exists := d.entries[key]; exists {
-			if now.Before(e.expiresAt) {
-				newOrder = append(newOrder, key)
-			} else {
-				delete(d.entries, key)
-			}
-		}
+	// Remove expired entries, soonest to expire first
+	for len(d.expiry) > 0 && !now.Before(d.expiry[0].expiresAt.Add(d.flapPeriod)) {
+		d.remove(d.expiry[0])
 	}
-	d.order = newOrder
 }
 
 // Size returns the current number of entries in the cache.
@@ -132,3 +359,116 @@ func (d *Deduplicator) Size() int {
 	defer d.mu.Unlock()
 	return len(d.entries)
 }
+
+// SetStore persists entries to store with Save and restores them with Load.
+func (d *Deduplicator) SetStore(store state.Store) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.store = store
+}
+
+// snapshotEntry is the persisted form of an entry, with Unix timestamps to
+// keep snapshots compact.
+type snapshotEntry struct {
+	Key       string  `json:"k"`
+	ExpiresAt int64   `json:"e"`
+	Count     int     `json:"c"`
+	FirstSeen int64   `json:"f"`
+	LastSeen  int64   `json:"l"`
+	Opened    []int64 `json:"o,omitempty"`
+	Notified  int64   `json:"n,omitempty"`
+}
+
+// Save writes the unexpired entries, and expired ones kept for flapping
+// detection, to the store, if one is set.
+func (d *Deduplicator) Save(ctx context.Context) error {
+	d.mu.Lock()
+	store := d.store
+	if store == nil {
+		d.mu.Unlock()
+		return nil
+	}
+	now := d.clock.Now()
+	snapshot := make([]snapshotEntry, 0, min(len(d.entries), maxSnapshotEntries))
+	// Most recently seen first, so the cap drops the least relevant entries
+	for el := d.lru.Front(); el != nil && len(snapshot) < maxSnapshotEntries; el = el.Next() {
+		e := el.Value.(*entry)
+		if now.Before(e.expiresAt.Add(d.flapPeriod)) {
+			s := snapshotEntry{
+				Key:       e.key,
+				ExpiresAt: e.expiresAt.Unix(),
+				Count:     e.count,
+				FirstSeen: e.firstSeen.Unix(),
+				LastSeen:  e.lastSeen.Unix(),
+				Notified:  e.notifiedAt.Unix(),
+			}
+			for _, t := range e.opened {
+				s.Opened = append(s.Opened, t.Unix())
+			}
+			snapshot = append(snapshot, s)
+		}
+	}
+	d.mu.Unlock()
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode dedup snapshot: %w", err)
+	}
+	return store.Save(ctx, data)
+}
+
+// Load restores entries from the store, if one is set, and returns how many
+// were restored. Expired entries are pruned and entries already present are
+// kept, since they are more recent.
+func (d *Deduplicator) Load(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	store := d.store
+	d.mu.Unlock()
+	if store == nil {
+		return 0, nil
+	}
+
+	data, err := store.Load(ctx)
+	if err != nil || data == nil {
+		return 0, err
+	}
+	var snapshot []snapshotEntry
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return 0, fmt.Errorf("failed to decode dedup snapshot: %w", err)
+	}
+
+	// Restore oldest first so LRU order matches the original
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].LastSeen < snapshot[j].LastSeen
+	})
+
+	now := d.clock.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	restored := 0
+	for _, s := range snapshot {
+		expiresAt := time.Unix(s.ExpiresAt, 0)
+		if !now.Before(expiresAt.Add(d.flapPeriod)) {
+			continue
+		}
+		if _, exists := d.entries[s.Key]; exists {
+			continue
+		}
+		e := d.addEntry(s.Key, now, expiresAt.Sub(now))
+		e.count = s.Count
+		e.firstSeen = time.Unix(s.FirstSeen, 0)
+		e.lastSeen = time.Unix(s.LastSeen, 0)
+		if s.Notified != 0 {
+			e.notifiedAt = time.Unix(s.Notified, 0)
+		}
+		if d.flapThreshold > 0 {
+			e.opened = e.opened[:0]
+			for _, t := range s.Opened {
+				e.opened = append(e.opened, time.Unix(t, 0))
+			}
+		}
+		restored++
+	}
+	return restored, nil
+}
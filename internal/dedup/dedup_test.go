@@ -1,12 +1,28 @@
 package dedup
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
+
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/imankulov/kube-sentry-events/internal/state"
 )
 
+// newTestDeduplicator returns a deduplicator driven by a fake clock, closed
+// when the test ends.
+func newTestDeduplicator(t testing.TB, window time.Duration) (*Deduplicator, *clocktesting.FakeClock) {
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	d := NewWithClock(window, clk)
+	t.Cleanup(d.Close)
+	return d, clk
+}
+
 func TestDeduplicator_FirstEventIsNew(t *testing.T) {
-	d := New(5 * time.Minute)
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
 
 	isNew, count, _, _ := d.Check("default", "my-pod", "OOMKilled")
 
@@ -19,7 +35,7 @@ func TestDeduplicator_FirstEventIsNew(t *testing.T) {
 }
 
 func TestDeduplicator_DuplicateWithinWindow(t *testing.T) {
-	d := New(5 * time.Minute)
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
 
 	// First event
 	isNew1, _, _, _ := d.Check("default", "my-pod", "OOMKilled")
@@ -47,7 +63,7 @@ func TestDeduplicator_DuplicateWithinWindow(t *testing.T) {
 }
 
 func TestDeduplicator_DifferentEvents(t *testing.T) {
-	d := New(5 * time.Minute)
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
 
 	// Different pod
 	isNew1, _, _, _ := d.Check("default", "pod-1", "OOMKilled")
@@ -71,8 +87,7 @@ func TestDeduplicator_DifferentEvents(t *testing.T) {
 }
 
 func TestDeduplicator_ExpiredEntry(t *testing.T) {
-	// Use a very short window for testing
-	d := New(10 * time.Millisecond)
+	d, clk := newTestDeduplicator(t, 10*time.Minute)
 
 	// First event
 	isNew1, _, _, _ := d.Check("default", "my-pod", "OOMKilled")
@@ -80,8 +95,8 @@ func TestDeduplicator_ExpiredEntry(t *testing.T) {
 		t.Error("expected first event to be new")
 	}
 
-	// Wait for expiration
-	time.Sleep(20 * time.Millisecond)
+	// Window passes
+	clk.Step(10 * time.Minute)
 
 	// Same event after expiration should be new again
 	isNew2, count2, _, _ := d.Check("default", "my-pod", "OOMKilled")
@@ -94,7 +109,7 @@ func TestDeduplicator_ExpiredEntry(t *testing.T) {
 }
 
 func TestDeduplicator_GetStats(t *testing.T) {
-	d := New(5 * time.Minute)
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
 
 	// No entry yet
 	_, _, _, exists := d.GetStats("default", "my-pod", "OOMKilled")
@@ -122,7 +137,7 @@ func TestDeduplicator_GetStats(t *testing.T) {
 }
 
 func TestDeduplicator_Size(t *testing.T) {
-	d := New(5 * time.Minute)
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
 
 	if d.Size() != 0 {
 		t.Errorf("expected initial size 0, got %d", d.Size())
@@ -144,33 +159,394 @@ func TestDeduplicator_Size(t *testing.T) {
 }
 
 func TestDeduplicator_MaxEntries(t *testing.T) {
-	d := New(5 * time.Minute)
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
 
-	// Add more than MaxEntries
-	for i := 0; i < MaxEntries+100; i++ {
+	// Add more than DefaultMaxEntries
+	for i := 0; i < DefaultMaxEntries+100; i++ {
 		d.Check("default", "pod-"+string(rune(i)), "OOMKilled")
 	}
 
-	if d.Size() > MaxEntries {
-		t.Errorf("expected size <= %d, got %d", MaxEntries, d.Size())
+	if d.Size() > DefaultMaxEntries {
+		t.Errorf("expected size <= %d, got %d", DefaultMaxEntries, d.Size())
+	}
+}
+
+func TestDeduplicator_SetMaxEntriesEvictsLeastRecentlySeen(t *testing.T) {
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
+	d.Check("default", "pod-1", "OOMKilled")
+	d.Check("default", "pod-2", "OOMKilled")
+	d.Check("default", "pod-3", "OOMKilled")
+	d.Check("default", "pod-1", "OOMKilled") // pod-2 is now the least recently seen
+
+	d.SetMaxEntries(2)
+	if d.Size() != 2 {
+		t.Fatalf("expected size 2 after lowering the limit, got %d", d.Size())
+	}
+	if _, _, _, exists := d.GetStats("default", "pod-2", "OOMKilled"); exists {
+		t.Error("expected least recently seen entry to be evicted")
+	}
+
+	d.Check("default", "pod-4", "OOMKilled")
+	if _, _, _, exists := d.GetStats("default", "pod-3", "OOMKilled"); exists {
+		t.Error("expected pod-3 to be evicted for pod-4")
+	}
+	if _, _, _, exists := d.GetStats("default", "pod-1", "OOMKilled"); !exists {
+		t.Error("expected recently seen pod-1 to be kept")
+	}
+}
+
+func TestDeduplicator_CleanupWithMixedWindows(t *testing.T) {
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+	d.CheckWindow("default", "pod-1", "Unhealthy", time.Second)
+	d.CheckWindow("default", "pod-2", "OOMKilled", time.Hour)
+	d.CheckWindow("default", "pod-3", "Unhealthy", time.Second)
+	clk.Step(time.Second)
+
+	// Re-creating an expired entry must not leave the old one behind
+	d.CheckWindow("default", "pod-3", "Unhealthy", time.Hour)
+	d.cleanup()
+
+	if d.Size() != 2 || d.lru.Len() != 2 || len(d.expiry) != 2 {
+		t.Errorf("expected 2 entries everywhere, got map %d, list %d, heap %d", d.Size(), d.lru.Len(), len(d.expiry))
+	}
+	if _, _, _, exists := d.GetStats("default", "pod-1", "Unhealthy"); exists {
+		t.Error("expected expired entry to be cleaned up")
+	}
+}
+
+func TestDeduplicator_CleanupOnTick(t *testing.T) {
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+	d.Check("default", "my-pod", "OOMKilled")
+
+	clk.Step(cleanupInterval)
+	if d.Size() != 1 {
+		t.Fatalf("expected unexpired entry to survive cleanup, got size %d", d.Size())
+	}
+
+	clk.Step(5 * cleanupInterval)
+	deadline := time.Now().Add(time.Second)
+	for d.Size() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if d.Size() != 0 {
+		t.Errorf("expected expired entry to be cleaned up on tick, got size %d", d.Size())
+	}
+}
+
+func TestDeduplicator_Close(t *testing.T) {
+	d := NewWithClock(5*time.Minute, clocktesting.NewFakeClock(time.Now()))
+	d.Check("default", "my-pod", "OOMKilled")
+
+	d.Close()
+	d.Close() // Closing twice is fine
+
+	if isNew, _, _, _ := d.Check("default", "my-pod", "OOMKilled"); isNew {
+		t.Error("expected entries to stay usable after Close")
 	}
 }
 
 func TestDeduplicator_TimestampTracking(t *testing.T) {
-	d := New(5 * time.Minute)
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
 
-	before := time.Now()
+	start := clk.Now()
 	d.Check("default", "my-pod", "OOMKilled")
-	time.Sleep(10 * time.Millisecond)
+	clk.Step(time.Minute)
 	d.Check("default", "my-pod", "OOMKilled")
-	after := time.Now()
 
 	_, firstSeen, lastSeen, _ := d.GetStats("default", "my-pod", "OOMKilled")
 
-	if firstSeen.Before(before) || firstSeen.After(after) {
-		t.Error("firstSeen timestamp out of expected range")
+	if !firstSeen.Equal(start) {
+		t.Errorf("expected firstSeen %v, got %v", start, firstSeen)
 	}
-	if lastSeen.Before(firstSeen) {
-		t.Error("lastSeen should be >= firstSeen")
+	if !lastSeen.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected lastSeen %v, got %v", start.Add(time.Minute), lastSeen)
+	}
+}
+
+func TestDeduplicator_CheckWindow(t *testing.T) {
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+
+	// Short per-reason window
+	d.CheckWindow("default", "my-pod", "Unhealthy", time.Minute)
+	clk.Step(2 * time.Minute)
+
+	isNew, _, _, _ := d.CheckWindow("default", "my-pod", "Unhealthy", time.Minute)
+	if !isNew {
+		t.Error("expected event to be new after its per-reason window expired")
+	}
+
+	// Zero window falls back to the default
+	d.CheckWindow("default", "my-pod", "OOMKilled", 0)
+	clk.Step(2 * time.Minute)
+
+	isNew, _, _, _ = d.CheckWindow("default", "my-pod", "OOMKilled", 0)
+	if isNew {
+		t.Error("expected default window to apply for zero window")
+	}
+}
+
+func TestDeduplicator_Forget(t *testing.T) {
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
+
+	d.Check("default", "Deployment/web", "CrashLoopBackOff")
+	d.Forget("default", "Deployment/web", "CrashLoopBackOff")
+
+	isNew, count, _, _ := d.Check("default", "Deployment/web", "CrashLoopBackOff")
+	if !isNew || count != 1 {
+		t.Errorf("expected forgotten event to be new, got isNew=%v count=%d", isNew, count)
+	}
+}
+
+func TestDeduplicator_Flapping(t *testing.T) {
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+	d.SetFlapping(3, time.Hour)
+	start := clk.Now()
+
+	// Recurs every six minutes, just after each window expires
+	var o Occurrence
+	for i := 0; i < 3; i++ {
+		o = d.Observe("default", "Deployment/web", "CrashLoopBackOff", 0)
+		if !o.IsNew {
+			t.Fatalf("occurrence %d: expected a new window", i+1)
+		}
+		if i < 2 && o.Flapping {
+			t.Fatalf("occurrence %d: expected not to flap yet", i+1)
+		}
+		clk.Step(6 * time.Minute)
+	}
+
+	if !o.Flapping || o.Recurrences != 3 {
+		t.Errorf("expected flapping after 3 recurrences, got flapping=%v recurrences=%d", o.Flapping, o.Recurrences)
+	}
+	if o.Count != 3 || !o.FirstSeen.Equal(start) {
+		t.Errorf("expected history across windows, got count %d first seen %v", o.Count, o.FirstSeen)
+	}
+
+	// Quiet for longer than the period: history is dropped
+	clk.Step(2 * time.Hour)
+	d.cleanup()
+	o = d.Observe("default", "Deployment/web", "CrashLoopBackOff", 0)
+	if o.Flapping || o.Count != 1 {
+		t.Errorf("expected a fresh entry after the period, got flapping=%v count=%d", o.Flapping, o.Count)
+	}
+}
+
+func TestDeduplicator_FlappingDuplicatesDontCount(t *testing.T) {
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+	d.SetFlapping(2, time.Hour)
+
+	// Recurs every four minutes, so the window never expires
+	for i := 0; i < 5; i++ {
+		o := d.Observe("default", "Deployment/web", "CrashLoopBackOff", 0)
+		if o.Flapping || o.Recurrences != 1 {
+			t.Fatalf("occurrence %d: expected one window, got flapping=%v recurrences=%d", i+1, o.Flapping, o.Recurrences)
+		}
+		clk.Step(4 * time.Minute)
+	}
+}
+
+func TestDeduplicator_ForgetKeepsFlappingHistory(t *testing.T) {
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
+	d.SetFlapping(2, time.Hour)
+
+	d.Check("default", "Deployment/web", "CrashLoopBackOff")
+	d.Forget("default", "Deployment/web", "CrashLoopBackOff")
+
+	o := d.Observe("default", "Deployment/web", "CrashLoopBackOff", 0)
+	if !o.IsNew || o.Count != 2 || !o.Flapping {
+		t.Errorf("expected recovering and failing again to flap, got isNew=%v count=%d flapping=%v", o.IsNew, o.Count, o.Flapping)
+	}
+}
+
+func TestDeduplicator_Renotify(t *testing.T) {
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+	d.SetRenotifyInterval(time.Hour)
+	start := clk.Now()
+
+	// Keeps firing every four minutes, so the window never expires
+	reminders := 0
+	var last Occurrence
+	for i := 0; i < 31; i++ {
+		last = d.Observe("default", "Deployment/web", "CrashLoopBackOff", 0)
+		if last.Remind {
+			reminders++
+			if last.IsNew {
+				t.Error("expected reminders only for duplicates")
+			}
+		}
+		clk.Step(4 * time.Minute)
+	}
+
+	// Reminders at 60m and 120m
+	if reminders != 2 {
+		t.Errorf("expected 2 reminders in two hours, got %d", reminders)
+	}
+	if last.Count != 31 || !last.FirstSeen.Equal(start) {
+		t.Errorf("expected ongoing count and first seen, got %d / %v", last.Count, last.FirstSeen)
+	}
+}
+
+func TestDeduplicator_SaveAndLoad(t *testing.T) {
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "dedup.json"))
+	ctx := context.Background()
+
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
+	d.SetStore(store)
+	d.Check("default", "Deployment/web", "CrashLoopBackOff")
+	d.Check("default", "Deployment/web", "CrashLoopBackOff")
+	_, _, firstSeen, _ := d.Check("default", "Deployment/web", "CrashLoopBackOff")
+	if err := d.Save(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A restarted process keeps deduplicating and counting
+	restarted, _ := newTestDeduplicator(t, 5*time.Minute)
+	restarted.SetStore(store)
+	n, err := restarted.Load(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 restored entry, got %d (%v)", n, err)
+	}
+	isNew, count, restoredFirstSeen, _ := restarted.Check("default", "Deployment/web", "CrashLoopBackOff")
+	if isNew || count != 4 {
+		t.Errorf("expected restored entry to be a duplicate with count 4, got isNew=%v count=%d", isNew, count)
+	}
+	if restoredFirstSeen.Unix() != firstSeen.Unix() {
+		t.Errorf("expected first seen to be kept, got %v want %v", restoredFirstSeen, firstSeen)
+	}
+}
+
+func TestDeduplicator_LoadPrunesExpired(t *testing.T) {
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "dedup.json"))
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+	now := clk.Now()
+	snapshot := fmt.Sprintf(`[
+		{"k":"default/Deployment/old/BackOff","e":%d,"c":3,"f":%d,"l":%d},
+		{"k":"default/Deployment/live/BackOff","e":%d,"c":2,"f":%d,"l":%d}
+	]`,
+		now.Add(-time.Minute).Unix(), now.Add(-time.Hour).Unix(), now.Add(-10*time.Minute).Unix(),
+		now.Add(time.Minute).Unix(), now.Add(-time.Hour).Unix(), now.Unix(),
+	)
+	if err := store.Save(context.Background(), []byte(snapshot)); err != nil {
+		t.Fatal(err)
+	}
+
+	d.SetStore(store)
+	d.Check("default", "Deployment/live", "BackOff") // Seen since startup, keeps its own state
+
+	n, err := d.Load(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 0 || d.Size() != 1 {
+		t.Errorf("expected expired and already known entries to be skipped, restored %d, size %d", n, d.Size())
+	}
+	if _, _, _, exists := d.GetStats("default", "Deployment/old", "BackOff"); exists {
+		t.Error("expected expired entry to be pruned")
+	}
+}
+
+func TestDeduplicator_SaveAndLoadFlappingHistory(t *testing.T) {
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "dedup.json"))
+	ctx := context.Background()
+
+	d, clk := newTestDeduplicator(t, 5*time.Minute)
+	d.SetFlapping(2, time.Hour)
+	d.SetStore(store)
+	d.Check("default", "Deployment/web", "CrashLoopBackOff")
+	clk.Step(10 * time.Minute) // Expired, but kept for its history
+	if err := d.Save(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	restarted := NewWithClock(5*time.Minute, clk)
+	t.Cleanup(restarted.Close)
+	restarted.SetFlapping(2, time.Hour)
+	restarted.SetStore(store)
+	if n, err := restarted.Load(ctx); err != nil || n != 1 {
+		t.Fatalf("expected 1 restored entry, got %d (%v)", n, err)
+	}
+	o := restarted.Observe("default", "Deployment/web", "CrashLoopBackOff", 0)
+	if !o.IsNew || !o.Flapping || o.Count != 2 {
+		t.Errorf("expected restored history to flap, got isNew=%v flapping=%v count=%d", o.IsNew, o.Flapping, o.Count)
+	}
+}
+
+func TestDeduplicator_WithoutStore(t *testing.T) {
+	d, _ := newTestDeduplicator(t, 5*time.Minute)
+	d.Check("default", "my-pod", "OOMKilled")
+
+	if err := d.Save(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n, err := d.Load(context.Background()); n != 0 || err != nil {
+		t.Errorf("expected nothing to load, got %d (%v)", n, err)
+	}
+}
+
+// benchmarkKeys returns n distinct pod names.
+func benchmarkKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("pod-%d", i)
+	}
+	return keys
+}
+
+// BenchmarkDeduplicator_CheckEvicting adds a distinct key per iteration to a
+// full cache, so every Check evicts.
+func BenchmarkDeduplicator_CheckEvicting(b *testing.B) {
+	d := New(time.Hour)
+	b.Cleanup(d.Close)
+	keys := benchmarkKeys(4 * DefaultMaxEntries)
+	for _, key := range keys[:DefaultMaxEntries] {
+		d.Check("default", key, "OOMKilled")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Check("default", keys[i%len(keys)], "OOMKilled")
+	}
+}
+
+// BenchmarkDeduplicator_CheckDuplicate touches keys already in a full cache.
+func BenchmarkDeduplicator_CheckDuplicate(b *testing.B) {
+	d := New(time.Hour)
+	b.Cleanup(d.Close)
+	keys := benchmarkKeys(DefaultMaxEntries)
+	for _, key := range keys {
+		d.Check("default", key, "OOMKilled")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Check("default", keys[i%len(keys)], "OOMKilled")
+	}
+}
+
+// BenchmarkDeduplicator_Cleanup runs the periodic cleanup on a full cache in
+// which nothing has expired, the common case between bursts.
+func BenchmarkDeduplicator_Cleanup(b *testing.B) {
+	d := New(time.Hour)
+	b.Cleanup(d.Close)
+	for _, key := range benchmarkKeys(DefaultMaxEntries) {
+		d.Check("default", key, "OOMKilled")
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.cleanup()
+	}
+}
+
+// BenchmarkDeduplicator_CheckExpired re-checks keys whose window has passed,
+// which replaces their entry.
+func BenchmarkDeduplicator_CheckExpired(b *testing.B) {
+	d := New(time.Nanosecond)
+	b.Cleanup(d.Close)
+	keys := benchmarkKeys(1000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Check("default", keys[i%len(keys)], "OOMKilled")
 	}
 }
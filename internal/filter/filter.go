@@ -1,8 +1,38 @@
 package filter
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/getsentry/sentry-go"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+// IgnoreAnnotation silences all events for a namespace or workload (and
+// everything it owns) when set to "true".
+const IgnoreAnnotation = "kube-sentry-events/ignore"
+
+// Rejection describes why the filter rejected an event.
+type Rejection string
+
+const (
+	// Accepted means the event passed all filters.
+	Accepted Rejection = ""
+	// RejectedNamespace means the event's namespace is not watched or is excluded.
+	RejectedNamespace Rejection = "namespace"
+	// RejectedReason means the event reason is not monitored.
+	RejectedReason Rejection = "reason"
+	// RejectedType means the event is not a Warning.
+	RejectedType Rejection = "type"
+	// RejectedNamespaceLabels means the namespace labels don't match the namespace selectors.
+	RejectedNamespaceLabels Rejection = "namespace_labels"
+	// RejectedObjectLabels means the involved object and its owners don't match the object selectors.
+	RejectedObjectLabels Rejection = "object_labels"
+	// RejectedOptOut means the namespace or an owning object carries IgnoreAnnotation.
+	RejectedOptOut Rejection = "opt_out"
 )
 
 // Filter determines which Kubernetes events should be sent to Sentry.
@@ -12,6 +42,13 @@ type Filter struct {
 	eventReasons      map[string]struct{}
 	eventThresholds   map[string]int32
 	severityMap       map[string]sentry.Level
+	dedupWindows      map[string]time.Duration
+
+	// Label selectors; nil means not configured
+	namespaceSelector        labels.Selector
+	excludeNamespaceSelector labels.Selector
+	objectSelector           labels.Selector
+	excludeObjectSelector    labels.Selector
 }
 
 // New creates a new event filter.
@@ -22,43 +59,140 @@ func New(namespaces, excludeNamespaces, eventReasons []string, thresholds map[st
 		eventReasons:      toSet(eventReasons),
 		eventThresholds:   thresholds,
 		severityMap:       defaultSeverityMap(),
+		dedupWindows:      map[string]time.Duration{},
 	}
 	return f
 }
 
+// SetSeverities overrides the Sentry severity for the given reasons.
+func (f *Filter) SetSeverities(severities map[string]sentry.Level) {
+	for reason, level := range severities {
+		f.severityMap[reason] = level
+	}
+}
+
+// SetDedupWindows sets per-reason deduplication windows.
+func (f *Filter) SetDedupWindows(windows map[string]time.Duration) {
+	for reason, window := range windows {
+		f.dedupWindows[reason] = window
+	}
+}
+
+// SetSelectors sets the include and exclude label selectors for namespaces and
+// involved objects. Empty strings leave the selector unset.
+func (f *Filter) SetSelectors(namespace, excludeNamespace, object, excludeObject string) error {
+	var err error
+	if f.namespaceSelector, err = parseSelector(namespace); err != nil {
+		return fmt.Errorf("invalid namespace selector: %w", err)
+	}
+	if f.excludeNamespaceSelector, err = parseSelector(excludeNamespace); err != nil {
+		return fmt.Errorf("invalid exclude namespace selector: %w", err)
+	}
+	if f.objectSelector, err = parseSelector(object); err != nil {
+		return fmt.Errorf("invalid object selector: %w", err)
+	}
+	if f.excludeObjectSelector, err = parseSelector(excludeObject); err != nil {
+		return fmt.Errorf("invalid exclude object selector: %w", err)
+	}
+	return nil
+}
+
 // ShouldProcess returns true if the event should be processed.
 // This checks namespace and event type filters, but NOT thresholds.
 // Use MeetsThreshold separately to check count thresholds.
 func (f *Filter) ShouldProcess(event *corev1.Event) bool {
+	return f.Check(event) == Accepted
+}
+
+// Check returns why the event is rejected, or Accepted if it should be processed.
+func (f *Filter) Check(event *corev1.Event) Rejection {
 	// Filter by namespace
 	ns := event.InvolvedObject.Namespace
 	if ns == "" {
 		ns = event.Namespace
 	}
 
-	// If specific namespaces are configured, only allow those
-	if len(f.namespaces) > 0 {
-		if _, ok := f.namespaces[ns]; !ok {
-			return false
+	// Namespace filters don't apply to cluster-scoped objects (e.g. Nodes)
+	if ns != "" {
+		// If specific namespaces are configured, only allow those
+		if len(f.namespaces) > 0 {
+			if _, ok := f.namespaces[ns]; !ok {
+				return RejectedNamespace
+			}
 		}
-	}
 
-	// Check exclude list
-	if _, excluded := f.excludeNamespaces[ns]; excluded {
-		return false
+		// Check exclude list
+		if _, excluded := f.excludeNamespaces[ns]; excluded {
+			return RejectedNamespace
+		}
 	}
 
 	// Filter by event reason
 	if _, ok := f.eventReasons[event.Reason]; !ok {
-		return false
+		return RejectedReason
 	}
 
 	// Only process Warning events (Normal events are informational)
 	if event.Type != corev1.EventTypeWarning {
-		return false
+		return RejectedType
 	}
 
-	return true
+	return Accepted
+}
+
+// CheckMetadata returns why the event is rejected based on the labels and
+// annotations of its namespace (nil for cluster-scoped or unknown namespaces)
+// and the involved object's owner chain, or Accepted.
+//
+// The object selector matches if any object in the chain matches, so labelling
+// either the Pod or its Deployment is enough; the exclude selector rejects if any
+// object matches. Objects whose metadata couldn't be fetched are skipped, and if
+// none were fetched the object selectors are not applied at all.
+//
+// An empty chain means the involved object's metadata is unknown (e.g. it was
+// deleted or RBAC forbids the lookup). It can't be shown to match the object
+// selector, so it's rejected if one is set; exclude selectors and opt-out
+// annotations can't match it either, so it's otherwise accepted.
+func (f *Filter) CheckMetadata(namespace *corev1.Namespace, chain []workload.Object) Rejection {
+	if namespace != nil {
+		if isIgnored(namespace.Annotations) {
+			return RejectedOptOut
+		}
+		nsLabels := labels.Set(namespace.Labels)
+		if f.namespaceSelector != nil && !f.namespaceSelector.Matches(nsLabels) {
+			return RejectedNamespaceLabels
+		}
+		if f.excludeNamespaceSelector != nil && f.excludeNamespaceSelector.Matches(nsLabels) {
+			return RejectedNamespaceLabels
+		}
+	}
+
+	if len(chain) == 0 && f.objectSelector != nil {
+		return RejectedObjectLabels
+	}
+
+	fetched, included := false, false
+	for _, obj := range chain {
+		if !obj.Fetched {
+			continue
+		}
+		fetched = true
+		if isIgnored(obj.Annotations) {
+			return RejectedOptOut
+		}
+		objLabels := labels.Set(obj.Labels)
+		if f.excludeObjectSelector != nil && f.excludeObjectSelector.Matches(objLabels) {
+			return RejectedObjectLabels
+		}
+		if f.objectSelector != nil && f.objectSelector.Matches(objLabels) {
+			included = true
+		}
+	}
+	if f.objectSelector != nil && fetched && !included {
+		return RejectedObjectLabels
+	}
+
+	return Accepted
 }
 
 // MeetsThreshold returns true if the event's count meets the minimum threshold.
@@ -90,6 +224,12 @@ func (f *Filter) GetSeverity(reason string) sentry.Level {
 	return sentry.LevelWarning
 }
 
+// GetDedupWindow returns the deduplication window for an event reason,
+// or 0 if the deduplicator's default window applies.
+func (f *Filter) GetDedupWindow(reason string) time.Duration {
+	return f.dedupWindows[reason]
+}
+
 func defaultSeverityMap() map[string]sentry.Level {
 	return map[string]sentry.Level{
 		// Error level - critical issues
@@ -102,6 +242,11 @@ func defaultSeverityMap() map[string]sentry.Level {
 		"ImagePullBackOff":   sentry.LevelError,
 		"ErrImagePull":       sentry.LevelError,
 		"FailedCreate":       sentry.LevelError,
+		"ContainerCrashed":   sentry.LevelError,
+
+		"BackoffLimitExceeded": sentry.LevelError,
+		"DeadlineExceeded":     sentry.LevelError,
+		"JobFailed":            sentry.LevelError,
 
 		// Warning level - issues that may self-resolve
 		"Unhealthy":    sentry.LevelWarning,
@@ -110,11 +255,30 @@ func defaultSeverityMap() map[string]sentry.Level {
 		"NodeNotReady": sentry.LevelWarning,
 		"FailedSync":   sentry.LevelWarning,
 
+		"NodeMemoryPressure": sentry.LevelWarning,
+		"NodeDiskPressure":   sentry.LevelWarning,
+		"NodePIDPressure":    sentry.LevelWarning,
+
+		"ContainerRestarted": sentry.LevelWarning,
+
 		// Info level - informational
 		"NodeReady": sentry.LevelInfo,
 	}
 }
 
+// parseSelector parses a label selector, returning nil for an empty string
+// (labels.Parse would return a selector matching everything).
+func parseSelector(selector string) (labels.Selector, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	return labels.Parse(selector)
+}
+
+func isIgnored(annotations map[string]string) bool {
+	return annotations[IgnoreAnnotation] == "true"
+}
+
 func toSet(slice []string) map[string]struct{} {
 	set := make(map[string]struct{}, len(slice))
 	for _, s := range slice {
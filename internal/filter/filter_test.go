@@ -2,10 +2,13 @@ package filter
 
 import (
 	"testing"
+	"time"
 
 	"github.com/getsentry/sentry-go"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/workload"
 )
 
 func newTestEvent(namespace, name, reason, eventType string) *corev1.Event {
@@ -214,3 +217,114 @@ func TestFilter_GetThreshold(t *testing.T) {
 		t.Errorf("expected Unknown threshold 1 (default), got %d", f.GetThreshold("Unknown"))
 	}
 }
+
+func TestFilter_Check_RejectionReasons(t *testing.T) {
+	f := New([]string{"production"}, []string{"kube-system"}, []string{"OOMKilled"}, defaultThresholds())
+
+	tests := []struct {
+		name     string
+		event    *corev1.Event
+		expected Rejection
+	}{
+		{"accepted", newTestEvent("production", "pod", "OOMKilled", corev1.EventTypeWarning), Accepted},
+		{"namespace", newTestEvent("development", "pod", "OOMKilled", corev1.EventTypeWarning), RejectedNamespace},
+		{"reason", newTestEvent("production", "pod", "Scheduled", corev1.EventTypeWarning), RejectedReason},
+		{"type", newTestEvent("production", "pod", "OOMKilled", corev1.EventTypeNormal), RejectedType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.Check(tt.event); got != tt.expected {
+				t.Errorf("Check() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilter_SetSeverities(t *testing.T) {
+	f := New(nil, nil, []string{"Unhealthy"}, defaultThresholds())
+	f.SetSeverities(map[string]sentry.Level{"Unhealthy": sentry.LevelError})
+
+	if got := f.GetSeverity("Unhealthy"); got != sentry.LevelError {
+		t.Errorf("expected overridden severity error, got %v", got)
+	}
+	if got := f.GetSeverity("OOMKilled"); got != sentry.LevelError {
+		t.Errorf("expected default severity to be kept, got %v", got)
+	}
+}
+
+func TestFilter_GetDedupWindow(t *testing.T) {
+	f := New(nil, nil, []string{"Unhealthy"}, defaultThresholds())
+	f.SetDedupWindows(map[string]time.Duration{"Unhealthy": 30 * time.Minute})
+
+	if got := f.GetDedupWindow("Unhealthy"); got != 30*time.Minute {
+		t.Errorf("expected Unhealthy window 30m, got %v", got)
+	}
+	if got := f.GetDedupWindow("OOMKilled"); got != 0 {
+		t.Errorf("expected 0 (default) window for OOMKilled, got %v", got)
+	}
+}
+
+func newTestNamespace(labels, annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: labels, Annotations: annotations}}
+}
+
+func newTestChain(podLabels, deploymentLabels, deploymentAnnotations map[string]string) []workload.Object {
+	return []workload.Object{
+		{Kind: "Pod", Name: "web-7d9f8c6b5-abcde", Labels: podLabels, Fetched: true},
+		{Kind: "ReplicaSet", Name: "web-7d9f8c6b5", Fetched: true},
+		{Kind: "Deployment", Name: "web", Labels: deploymentLabels, Annotations: deploymentAnnotations, Fetched: true},
+	}
+}
+
+func TestFilter_CheckMetadata(t *testing.T) {
+	f := New(nil, nil, []string{"OOMKilled"}, defaultThresholds())
+	if err := f.SetSelectors("env=prod", "tier=sandbox", "team=payments", "component=cache"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	prod := newTestNamespace(map[string]string{"env": "prod"}, nil)
+	payments := map[string]string{"team": "payments"}
+	ignore := map[string]string{IgnoreAnnotation: "true"}
+
+	tests := []struct {
+		name      string
+		namespace *corev1.Namespace
+		chain     []workload.Object
+		expected  Rejection
+	}{
+		{"deployment label matches", prod, newTestChain(nil, payments, nil), Accepted},
+		{"pod label matches", prod, newTestChain(payments, nil, nil), Accepted},
+		{"no object matches", prod, newTestChain(nil, map[string]string{"team": "search"}, nil), RejectedObjectLabels},
+		{"object excluded", prod, newTestChain(map[string]string{"team": "payments", "component": "cache"}, nil, nil), RejectedObjectLabels},
+		{"namespace not selected", newTestNamespace(map[string]string{"env": "dev"}, nil), newTestChain(nil, payments, nil), RejectedNamespaceLabels},
+		{"namespace excluded", newTestNamespace(map[string]string{"env": "prod", "tier": "sandbox"}, nil), newTestChain(nil, payments, nil), RejectedNamespaceLabels},
+		{"deployment opted out", prod, newTestChain(nil, payments, ignore), RejectedOptOut},
+		{"namespace opted out", newTestNamespace(map[string]string{"env": "prod"}, ignore), newTestChain(nil, payments, nil), RejectedOptOut},
+		{"unknown metadata can't match the object selector", prod, nil, RejectedObjectLabels},
+		{"unfetched owners skip object selectors", prod, []workload.Object{{Kind: "Node", Name: "node-1"}}, Accepted},
+		{"cluster-scoped skips namespace selectors", nil, newTestChain(nil, payments, nil), Accepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := f.CheckMetadata(tt.namespace, tt.chain); got != tt.expected {
+				t.Errorf("CheckMetadata() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilter_CheckMetadata_NoSelectors(t *testing.T) {
+	f := New(nil, nil, []string{"OOMKilled"}, defaultThresholds())
+
+	if got := f.CheckMetadata(newTestNamespace(nil, nil), newTestChain(nil, nil, nil)); got != Accepted {
+		t.Errorf("expected Accepted without selectors, got %q", got)
+	}
+	if got := f.CheckMetadata(newTestNamespace(nil, nil), nil); got != Accepted {
+		t.Errorf("expected unknown metadata to be accepted without selectors, got %q", got)
+	}
+	if err := f.SetSelectors("env in prod", "", "", ""); err == nil {
+		t.Error("expected error for invalid selector")
+	}
+}
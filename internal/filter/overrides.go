@@ -0,0 +1,157 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+// Annotations on a namespace or workload that override the global rules for
+// its events. Thresholds and severity accept either a bare value applying to
+// every reason or "Reason:value,Reason:value" pairs.
+const (
+	ThresholdsAnnotation = "kube-sentry-events/thresholds"
+	SeverityAnnotation   = "kube-sentry-events/severity"
+	ProjectAnnotation    = "kube-sentry-events/dsn-project"
+	MuteUntilAnnotation  = "kube-sentry-events/mute-until" // RFC 3339 timestamp
+)
+
+// validLevels are the Sentry levels accepted in the severity annotation.
+var validLevels = []sentry.Level{sentry.LevelDebug, sentry.LevelInfo, sentry.LevelWarning, sentry.LevelError, sentry.LevelFatal}
+
+// Override records the annotation that replaced a global setting for an event.
+type Override struct {
+	Value  string
+	Source string // e.g. "Namespace/payments" or "Deployment/web"
+}
+
+// String formats the override for logs and dry-run output.
+func (o Override) String() string {
+	return o.Value + " (" + o.Source + ")"
+}
+
+// Rule is the effective configuration for a single event after applying
+// annotation overrides on top of the global filter rules.
+type Rule struct {
+	Threshold int32 // 0 means no threshold
+	Severity  sentry.Level
+	Project   string // Sentry project to route to; empty means the default
+	MuteUntil time.Time
+	// Overrides maps each overridden setting ("threshold", "severity", "project",
+	// "mute-until") to the annotation that won
+	Overrides map[string]Override
+}
+
+// MeetsThreshold returns true if the event's count meets the rule's threshold.
+func (r Rule) MeetsThreshold(event *corev1.Event) bool {
+	return r.Threshold == 0 || event.Count >= r.Threshold
+}
+
+// Muted returns true if issues are muted at the given time.
+func (r Rule) Muted(now time.Time) bool {
+	return now.Before(r.MuteUntil)
+}
+
+// RuleFor returns the effective rule for an event reason. Annotations are
+// applied from the namespace first, then down the owner chain from the
+// top-level workload to the involved object, so the closest object wins
+// (workload > namespace > global). Invalid annotation values are skipped and
+// reported in the returned error; the rule is still usable.
+func (f *Filter) RuleFor(reason string, namespace *corev1.Namespace, chain []workload.Object) (Rule, error) {
+	rule := Rule{
+		Threshold: f.eventThresholds[reason],
+		Severity:  f.GetSeverity(reason),
+		Overrides: map[string]Override{},
+	}
+
+	var errs []error
+	if namespace != nil {
+		errs = append(errs, rule.apply(reason, "Namespace/"+namespace.Name, namespace.Annotations)...)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i].Fetched {
+			errs = append(errs, rule.apply(reason, chain[i].Kind+"/"+chain[i].Name, chain[i].Annotations)...)
+		}
+	}
+
+	return rule, errors.Join(errs...)
+}
+
+// apply overrides the rule with the annotations of one object.
+func (r *Rule) apply(reason, source string, annotations map[string]string) []error {
+	var errs []error
+
+	if value, ok := valueForReason(annotations[ThresholdsAnnotation], reason); ok {
+		threshold, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || threshold < 1 {
+			errs = append(errs, fmt.Errorf("%s: invalid %s %q", source, ThresholdsAnnotation, value))
+		} else {
+			r.Threshold = int32(threshold)
+			r.Overrides["threshold"] = Override{Value: value, Source: source}
+		}
+	}
+
+	if value, ok := valueForReason(annotations[SeverityAnnotation], reason); ok {
+		level := sentry.Level(value)
+		if !slices.Contains(validLevels, level) {
+			errs = append(errs, fmt.Errorf("%s: invalid %s %q", source, SeverityAnnotation, value))
+		} else {
+			r.Severity = level
+			r.Overrides["severity"] = Override{Value: value, Source: source}
+		}
+	}
+
+	if value := strings.TrimSpace(annotations[ProjectAnnotation]); value != "" {
+		r.Project = value
+		r.Overrides["project"] = Override{Value: value, Source: source}
+	}
+
+	if value := strings.TrimSpace(annotations[MuteUntilAnnotation]); value != "" {
+		until, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid %s %q", source, MuteUntilAnnotation, value))
+		} else {
+			r.MuteUntil = until
+			r.Overrides["mute-until"] = Override{Value: value, Source: source}
+		}
+	}
+
+	return errs
+}
+
+// valueForReason extracts the value for reason from an annotation that is either
+// a bare value or "Reason:value" pairs. A pair for the reason wins over a bare value.
+func valueForReason(annotation, reason string) (string, bool) {
+	var fallback string
+	found := false
+	for _, item := range splitAndTrim(annotation) {
+		key, value, isPair := strings.Cut(item, ":")
+		if !isPair {
+			fallback, found = item, true
+			continue
+		}
+		if strings.TrimSpace(key) == reason {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return fallback, found
+}
+
+func splitAndTrim(s string) []string {
+	parts := strings.Split(s, ",")
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
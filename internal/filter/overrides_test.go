@@ -0,0 +1,124 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+func newOverrideNamespace(annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Annotations: annotations}}
+}
+
+func newOverrideChain(deploymentAnnotations map[string]string) []workload.Object {
+	return []workload.Object{
+		{Kind: "Pod", Name: "api-7d9f8c6b5-abcde", Fetched: true},
+		{Kind: "ReplicaSet", Name: "api-7d9f8c6b5", Fetched: true},
+		{Kind: "Deployment", Name: "api", Annotations: deploymentAnnotations, Fetched: true},
+	}
+}
+
+func TestFilter_RuleFor_Global(t *testing.T) {
+	f := New(nil, nil, []string{"Unhealthy"}, defaultThresholds())
+
+	rule, err := f.RuleFor("Unhealthy", newOverrideNamespace(nil), newOverrideChain(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rule.Threshold != 5 || rule.Severity != sentry.LevelWarning {
+		t.Errorf("expected global threshold 5 and warning, got %d and %v", rule.Threshold, rule.Severity)
+	}
+	if len(rule.Overrides) != 0 {
+		t.Errorf("expected no overrides, got %v", rule.Overrides)
+	}
+}
+
+func TestFilter_RuleFor_Precedence(t *testing.T) {
+	f := New(nil, nil, []string{"Unhealthy"}, defaultThresholds())
+	namespace := newOverrideNamespace(map[string]string{
+		ThresholdsAnnotation: "20",
+		SeverityAnnotation:   "info",
+		ProjectAnnotation:    "payments",
+	})
+	chain := newOverrideChain(map[string]string{
+		ThresholdsAnnotation: "BackOff:2, Unhealthy:10",
+		ProjectAnnotation:    "payments-api",
+	})
+
+	rule, err := f.RuleFor("Unhealthy", namespace, chain)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if rule.Threshold != 10 || rule.Overrides["threshold"].Source != "Deployment/api" {
+		t.Errorf("expected workload threshold 10, got %d from %v", rule.Threshold, rule.Overrides["threshold"])
+	}
+	if rule.Severity != sentry.LevelInfo || rule.Overrides["severity"].Source != "Namespace/payments" {
+		t.Errorf("expected namespace severity info, got %v from %v", rule.Severity, rule.Overrides["severity"])
+	}
+	if rule.Project != "payments-api" {
+		t.Errorf("expected workload project, got %q", rule.Project)
+	}
+	if got := rule.Overrides["threshold"].String(); got != "10 (Deployment/api)" {
+		t.Errorf("unexpected override string %q", got)
+	}
+}
+
+func TestFilter_RuleFor_MuteUntil(t *testing.T) {
+	f := New(nil, nil, []string{"Unhealthy"}, defaultThresholds())
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	rule, err := f.RuleFor("Unhealthy", nil, newOverrideChain(map[string]string{
+		MuteUntilAnnotation: until.Format(time.RFC3339),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !rule.Muted(time.Now()) {
+		t.Error("expected rule to be muted now")
+	}
+	if rule.Muted(until.Add(time.Second)) {
+		t.Error("expected mute to expire")
+	}
+}
+
+func TestFilter_RuleFor_InvalidAnnotations(t *testing.T) {
+	f := New(nil, nil, []string{"Unhealthy"}, defaultThresholds())
+
+	rule, err := f.RuleFor("Unhealthy", newOverrideNamespace(map[string]string{
+		SeverityAnnotation: "error",
+	}), newOverrideChain(map[string]string{
+		ThresholdsAnnotation: "lots",
+		SeverityAnnotation:   "catastrophic",
+		MuteUntilAnnotation:  "tomorrow",
+	}))
+
+	if err == nil {
+		t.Error("expected error for invalid annotations")
+	}
+	if rule.Threshold != 5 {
+		t.Errorf("expected invalid threshold to be ignored, got %d", rule.Threshold)
+	}
+	if rule.Severity != sentry.LevelError {
+		t.Errorf("expected namespace severity to survive invalid workload value, got %v", rule.Severity)
+	}
+	if !rule.MuteUntil.IsZero() {
+		t.Errorf("expected invalid mute-until to be ignored, got %v", rule.MuteUntil)
+	}
+}
+
+func TestRule_MeetsThreshold(t *testing.T) {
+	event := newTestEventWithCount("default", "pod", "FailedSync", corev1.EventTypeWarning, 0)
+
+	if !(Rule{}).MeetsThreshold(event) {
+		t.Error("expected no threshold to always be met")
+	}
+	if (Rule{Threshold: 2}).MeetsThreshold(event) {
+		t.Error("expected count 0 to be below threshold 2")
+	}
+}
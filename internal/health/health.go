@@ -0,0 +1,125 @@
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Checker tracks watch and Sentry health for the /healthz and /readyz probes.
+// All methods are safe to call on a nil Checker, which makes health tracking optional.
+type Checker struct {
+	mu           sync.Mutex
+	maxIdle      time.Duration
+	now          func() time.Time
+	watching     bool
+	standby      bool
+	sentryReady  bool
+	lastActivity time.Time
+}
+
+// New creates a health checker. Liveness fails if no watch event or bookmark
+// has been received for maxIdle while watching.
+func New(maxIdle time.Duration) *Checker {
+	return &Checker{
+		maxIdle:      maxIdle,
+		now:          time.Now,
+		lastActivity: time.Now(),
+	}
+}
+
+// SetSentryReady records whether the Sentry client is initialised.
+func (c *Checker) SetSentryReady(ready bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sentryReady = ready
+}
+
+// SetWatching records whether a watch is currently established.
+func (c *Checker) SetWatching(watching bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.watching = watching
+	if watching {
+		c.lastActivity = c.now()
+	}
+}
+
+// SetStandby records whether this replica is a leader election standby.
+// Standbys don't watch, so they are ready and live without watch activity.
+func (c *Checker) SetStandby(standby bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.standby = standby
+	c.lastActivity = c.now()
+}
+
+// Touch records watch activity (an event or a bookmark).
+func (c *Checker) Touch() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastActivity = c.now()
+}
+
+// Live returns an error if the watch has been idle for longer than maxIdle.
+func (c *Checker) Live() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.standby {
+		return nil
+	}
+	if idle := c.now().Sub(c.lastActivity); idle > c.maxIdle {
+		return fmt.Errorf("no watch activity for %s (max %s)", idle.Round(time.Second), c.maxIdle)
+	}
+	return nil
+}
+
+// Ready returns an error unless Sentry is initialised and a watch is established.
+func (c *Checker) Ready() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.sentryReady {
+		return fmt.Errorf("sentry not initialised")
+	}
+	if !c.watching && !c.standby {
+		return fmt.Errorf("watch not established")
+	}
+	return nil
+}
+
+// Register adds the /healthz and /readyz handlers to the mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", probeHandler(c.Live))
+	mux.HandleFunc("/readyz", probeHandler(c.Ready))
+}
+
+func probeHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintln(w, "ok")
+	}
+}
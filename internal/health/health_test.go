@@ -0,0 +1,96 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestChecker returns a checker whose clock is controlled by the returned pointer.
+func newTestChecker(maxIdle time.Duration) (*Checker, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := New(maxIdle)
+	c.now = func() time.Time { return now }
+	c.lastActivity = now
+	return c, &now
+}
+
+func TestChecker_ReadyRequiresWatchAndSentry(t *testing.T) {
+	c, _ := newTestChecker(time.Minute)
+
+	if c.Ready() == nil {
+		t.Error("expected not ready before initialisation")
+	}
+
+	c.SetSentryReady(true)
+	if c.Ready() == nil {
+		t.Error("expected not ready without an established watch")
+	}
+
+	c.SetWatching(true)
+	if err := c.Ready(); err != nil {
+		t.Errorf("expected ready, got %v", err)
+	}
+
+	c.SetWatching(false)
+	if c.Ready() == nil {
+		t.Error("expected not ready after watch error")
+	}
+}
+
+func TestChecker_StandbyIsReady(t *testing.T) {
+	c, _ := newTestChecker(time.Minute)
+	c.SetSentryReady(true)
+	c.SetStandby(true)
+
+	if err := c.Ready(); err != nil {
+		t.Errorf("expected standby to be ready, got %v", err)
+	}
+}
+
+func TestChecker_LivenessFailsWhenIdle(t *testing.T) {
+	c, now := newTestChecker(time.Minute)
+	c.SetWatching(true)
+
+	*now = now.Add(30 * time.Second)
+	if err := c.Live(); err != nil {
+		t.Errorf("expected live within maxIdle, got %v", err)
+	}
+
+	*now = now.Add(time.Minute)
+	if c.Live() == nil {
+		t.Error("expected liveness to fail after maxIdle without activity")
+	}
+
+	c.Touch()
+	if err := c.Live(); err != nil {
+		t.Errorf("expected live after bookmark, got %v", err)
+	}
+}
+
+func TestChecker_NilIsHealthy(t *testing.T) {
+	var c *Checker
+	c.Touch()
+	if c.Live() != nil || c.Ready() != nil {
+		t.Error("expected nil checker to report healthy")
+	}
+}
+
+func TestChecker_Handlers(t *testing.T) {
+	c, _ := newTestChecker(time.Minute)
+	mux := http.NewServeMux()
+	c.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected /readyz 503, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected /healthz 200, got %d", rec.Code)
+	}
+}
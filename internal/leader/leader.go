@@ -0,0 +1,106 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Config configures Lease-based leader election.
+type Config struct {
+	LeaseName      string
+	LeaseNamespace string
+	Identity       string
+
+	// Timings follow the client-go defaults scaled down so standbys take over within seconds
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// DefaultConfig returns a config with the default lease timings.
+func DefaultConfig(leaseName, leaseNamespace, identity string) Config {
+	return Config{
+		LeaseName:      leaseName,
+		LeaseNamespace: leaseNamespace,
+		Identity:       identity,
+		LeaseDuration:  15 * time.Second,
+		RenewDeadline:  10 * time.Second,
+		RetryPeriod:    2 * time.Second,
+	}
+}
+
+// RunFunc is called when this replica becomes the leader. The context is cancelled
+// when leadership is lost. cutoff is the earliest time the previous leader may have
+// stopped processing; anything observed before it has already been handled.
+type RunFunc func(ctx context.Context, cutoff time.Time)
+
+// Run campaigns for the lease and calls run while this replica holds it.
+// It blocks until ctx is cancelled, re-joining the election whenever leadership
+// is lost, but only once the previous run has returned.
+func Run(ctx context.Context, client kubernetes.Interface, cfg Config, logger *slog.Logger, run RunFunc) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      cfg.LeaseName,
+			Namespace: cfg.LeaseNamespace,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: cfg.Identity,
+		},
+	}
+
+	// Held while run runs. The elector starts run in its own goroutine and
+	// doesn't wait for it, so a new term could otherwise overlap the last one.
+	var running sync.Mutex
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		ReleaseOnCancel: true, // Hand over immediately on graceful shutdown
+		Name:            cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				running.Lock()
+				defer running.Unlock()
+				if ctx.Err() != nil {
+					// Leadership was lost before this term got going
+					return
+				}
+				logger.Info("acquired leadership", "identity", cfg.Identity, "lease", cfg.LeaseNamespace+"/"+cfg.LeaseName)
+				run(ctx, time.Now().Add(-cfg.LeaseDuration))
+			},
+			OnStoppedLeading: func() {
+				logger.Info("stopped leading", "identity", cfg.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != cfg.Identity {
+					logger.Info("standing by, another replica is leading", "leader", identity)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create leader elector: %w", err)
+	}
+
+	for {
+		elector.Run(ctx)
+		// Wait for the term's run to return before campaigning again
+		running.Lock()
+		err := ctx.Err()
+		running.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
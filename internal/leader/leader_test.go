@@ -0,0 +1,164 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testConfig(identity string) Config {
+	return Config{
+		LeaseName:      "kube-sentry-events",
+		LeaseNamespace: "monitoring",
+		Identity:       identity,
+		LeaseDuration:  time.Second,
+		RenewDeadline:  500 * time.Millisecond,
+		RetryPeriod:    100 * time.Millisecond,
+	}
+}
+
+func TestRun_AcquiresLeadership(t *testing.T) {
+	client := fake.NewClientset()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan time.Time, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, client, testConfig("replica-a"), logger, func(ctx context.Context, cutoff time.Time) {
+			started <- cutoff
+			<-ctx.Done()
+		})
+	}()
+
+	select {
+	case cutoff := <-started:
+		if !cutoff.Before(time.Now()) {
+			t.Error("expected cutoff to be in the past")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected to acquire leadership")
+	}
+
+	lease, err := client.CoordinationV1().Leases("monitoring").Get(context.Background(), "kube-sentry-events", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected lease to be created: %v", err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != "replica-a" {
+		t.Errorf("expected replica-a to hold the lease, got %v", lease.Spec.HolderIdentity)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Run to return after cancel")
+	}
+}
+
+func TestRun_StandbyTakesOver(t *testing.T) {
+	client := fake.NewClientset()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	leaderStarted := make(chan struct{}, 1)
+	leaderDone := make(chan struct{})
+	go func() {
+		_ = Run(leaderCtx, client, testConfig("replica-a"), logger, func(ctx context.Context, _ time.Time) {
+			leaderStarted <- struct{}{}
+			<-ctx.Done()
+		})
+		close(leaderDone)
+	}()
+
+	select {
+	case <-leaderStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected replica-a to lead")
+	}
+
+	standbyCtx, stopStandby := context.WithCancel(context.Background())
+	defer stopStandby()
+	standbyStarted := make(chan struct{}, 1)
+	go func() {
+		_ = Run(standbyCtx, client, testConfig("replica-b"), logger, func(ctx context.Context, _ time.Time) {
+			standbyStarted <- struct{}{}
+			<-ctx.Done()
+		})
+	}()
+
+	// Standby must not lead while replica-a holds the lease
+	select {
+	case <-standbyStarted:
+		t.Fatal("standby acquired leadership while leader was alive")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	stopLeader()
+	<-leaderDone
+
+	select {
+	case <-standbyStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected standby to take over")
+	}
+}
+
+func TestRun_RegainsLeadershipAfterPreviousRunReturns(t *testing.T) {
+	client := fake.NewClientset()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Fail lease renewals to make the leader lose its lease
+	var failRenewals atomic.Bool
+	client.PrependReactor("update", "leases", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failRenewals.Load() {
+			return true, nil, errors.New("apiserver unavailable")
+		}
+		return false, nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var active, overlaps atomic.Int32
+	terms := make(chan struct{}, 2)
+	go func() {
+		_ = Run(ctx, client, testConfig("replica-a"), logger, func(ctx context.Context, _ time.Time) {
+			if active.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			defer active.Add(-1)
+			terms <- struct{}{}
+
+			<-ctx.Done()
+			// Let the lease be renewed again, then shut down slowly
+			failRenewals.Store(false)
+			time.Sleep(300 * time.Millisecond)
+		})
+	}()
+
+	for term := 1; term <= 2; term++ {
+		select {
+		case <-terms:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected term %d to start", term)
+		}
+		if term == 1 {
+			failRenewals.Store(true)
+		}
+	}
+	if overlaps.Load() != 0 {
+		t.Error("expected the new term to wait for the previous run to return")
+	}
+}
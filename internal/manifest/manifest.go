@@ -0,0 +1,386 @@
+// Package manifest fetches sanitised snapshots of the objects involved in events.
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/imankulov/kube-sentry-events/internal/cache"
+)
+
+// lastAppliedAnnotation holds the last applied manifest, which may include
+// values stripped from the live object.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// redacted replaces literal environment variable values.
+const redacted = "[redacted]"
+
+// Snapshot is a sanitised copy of an object.
+type Snapshot struct {
+	Kind string
+	Name string
+	YAML []byte
+	// Scheduling summarises the pod spec's scheduling constraints; nil for
+	// objects without one
+	Scheduling map[string]interface{}
+}
+
+// cacheEntry holds a snapshot or the error fetching it.
+type cacheEntry struct {
+	snapshot *Snapshot
+	err      error
+}
+
+// Fetcher gets objects and turns them into snapshots. Results, including
+// failures such as RBAC denials, are cached for the configured TTL.
+type Fetcher struct {
+	client kubernetes.Interface
+	cache  *cache.Cache[cacheEntry]
+}
+
+// NewFetcher creates a new manifest fetcher.
+func NewFetcher(client kubernetes.Interface, ttl time.Duration) *Fetcher {
+	return &Fetcher{
+		client: client,
+		cache:  cache.New[cacheEntry](ttl, cache.DefaultMaxEntries),
+	}
+}
+
+// Fetch returns a snapshot of the referenced object with managed fields, the
+// last applied configuration and literal environment variable values removed.
+// Kinds that may hold secrets (e.g. Secrets, ConfigMaps) are never fetched.
+func (f *Fetcher) Fetch(ctx context.Context, namespace string, ref corev1.ObjectReference) (*Snapshot, error) {
+	if !IsSupportedKind(ref.Kind) {
+		return nil, fmt.Errorf("unsupported kind %q", ref.Kind)
+	}
+
+	key := ref.Kind + "/" + namespace + "/" + ref.Name
+	if e, ok := f.cache.Get(key); ok {
+		return e.snapshot, e.err
+	}
+
+	snapshot, err := f.fetch(ctx, ref.Kind, namespace, ref.Name)
+	if err != nil {
+		err = fmt.Errorf("failed to get %s %s/%s: %w", ref.Kind, namespace, ref.Name, err)
+	}
+
+	// Don't remember transient failures; a denial or a deleted object won't change soon
+	if err == nil || apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+		f.cache.Set(key, cacheEntry{snapshot: snapshot, err: err})
+	}
+	return snapshot, err
+}
+
+func (f *Fetcher) fetch(ctx context.Context, kind, namespace, name string) (*Snapshot, error) {
+	opts := metav1.GetOptions{}
+	var (
+		obj      interface{}
+		meta     *metav1.ObjectMeta
+		typeMeta *metav1.TypeMeta
+		spec     *corev1.PodSpec
+		template *metav1.ObjectMeta // the pod template's metadata, if any
+	)
+	switch kind {
+	case "Pod":
+		pod, err := f.client.CoreV1().Pods(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta, spec = pod, &pod.ObjectMeta, &pod.TypeMeta, &pod.Spec
+		typeMeta.APIVersion = "v1"
+	case "PersistentVolumeClaim":
+		pvc, err := f.client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta = pvc, &pvc.ObjectMeta, &pvc.TypeMeta
+		typeMeta.APIVersion = "v1"
+	case "ReplicaSet":
+		rs, err := f.client.AppsV1().ReplicaSets(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta, spec = rs, &rs.ObjectMeta, &rs.TypeMeta, &rs.Spec.Template.Spec
+		template = &rs.Spec.Template.ObjectMeta
+		typeMeta.APIVersion = appsv1.SchemeGroupVersion.String()
+	case "Deployment":
+		d, err := f.client.AppsV1().Deployments(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta, spec = d, &d.ObjectMeta, &d.TypeMeta, &d.Spec.Template.Spec
+		template = &d.Spec.Template.ObjectMeta
+		typeMeta.APIVersion = appsv1.SchemeGroupVersion.String()
+	case "StatefulSet":
+		sts, err := f.client.AppsV1().StatefulSets(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta, spec = sts, &sts.ObjectMeta, &sts.TypeMeta, &sts.Spec.Template.Spec
+		template = &sts.Spec.Template.ObjectMeta
+		typeMeta.APIVersion = appsv1.SchemeGroupVersion.String()
+	case "DaemonSet":
+		ds, err := f.client.AppsV1().DaemonSets(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta, spec = ds, &ds.ObjectMeta, &ds.TypeMeta, &ds.Spec.Template.Spec
+		template = &ds.Spec.Template.ObjectMeta
+		typeMeta.APIVersion = appsv1.SchemeGroupVersion.String()
+	case "Job":
+		job, err := f.client.BatchV1().Jobs(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta, spec = job, &job.ObjectMeta, &job.TypeMeta, &job.Spec.Template.Spec
+		template = &job.Spec.Template.ObjectMeta
+		typeMeta.APIVersion = batchv1.SchemeGroupVersion.String()
+	case "CronJob":
+		cj, err := f.client.BatchV1().CronJobs(namespace).Get(ctx, name, opts)
+		if err != nil {
+			return nil, err
+		}
+		obj, meta, typeMeta, spec = cj, &cj.ObjectMeta, &cj.TypeMeta, &cj.Spec.JobTemplate.Spec.Template.Spec
+		template = &cj.Spec.JobTemplate.Spec.Template.ObjectMeta
+		redactValues(cj.Spec.JobTemplate.Annotations)
+		typeMeta.APIVersion = batchv1.SchemeGroupVersion.String()
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", kind)
+	}
+
+	// Typed clients drop the type meta; restore it so the YAML is complete.
+	// Objects from the client are copies, so they can be modified in place.
+	typeMeta.Kind = kind
+	meta.ManagedFields = nil
+	delete(meta.Annotations, lastAppliedAnnotation)
+	redactValues(meta.Annotations)
+	if template != nil {
+		redactValues(template.Annotations)
+	}
+
+	snapshot := &Snapshot{Kind: kind, Name: name}
+	if spec != nil {
+		redactSpec(spec)
+		snapshot.Scheduling = scheduling(spec)
+	}
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal: %w", err)
+	}
+	snapshot.YAML = data
+	return snapshot, nil
+}
+
+// redactSpec replaces the literals in a pod spec that often hold credentials:
+// environment variable values, command arguments (the executable is kept),
+// exec probe and hook commands, and volume driver attributes. References to
+// Secrets and ConfigMaps are kept.
+func redactSpec(spec *corev1.PodSpec) {
+	redactContainer := func(c *corev1.Container) {
+		for i := range c.Env {
+			if c.Env[i].Value != "" {
+				c.Env[i].Value = redacted
+			}
+		}
+		if len(c.Command) > 1 {
+			redactStrings(c.Command[1:])
+		}
+		redactStrings(c.Args)
+		for _, probe := range []*corev1.Probe{c.LivenessProbe, c.ReadinessProbe, c.StartupProbe} {
+			if probe != nil {
+				redactExec(probe.Exec)
+			}
+		}
+		if c.Lifecycle != nil {
+			for _, hook := range []*corev1.LifecycleHandler{c.Lifecycle.PostStart, c.Lifecycle.PreStop} {
+				if hook != nil {
+					redactExec(hook.Exec)
+				}
+			}
+		}
+	}
+	for i := range spec.InitContainers {
+		redactContainer(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		redactContainer(&spec.Containers[i])
+	}
+	for i := range spec.EphemeralContainers {
+		// Ephemeral containers share the container fields that matter here
+		c := corev1.Container(spec.EphemeralContainers[i].EphemeralContainerCommon)
+		redactContainer(&c)
+		spec.EphemeralContainers[i].EphemeralContainerCommon = corev1.EphemeralContainerCommon(c)
+	}
+
+	for _, v := range spec.Volumes {
+		if v.CSI != nil {
+			redactValues(v.CSI.VolumeAttributes)
+		}
+		if v.FlexVolume != nil {
+			redactValues(v.FlexVolume.Options)
+		}
+	}
+}
+
+// redactExec replaces an exec action's arguments; the executable is kept.
+func redactExec(exec *corev1.ExecAction) {
+	if exec != nil && len(exec.Command) > 1 {
+		redactStrings(exec.Command[1:])
+	}
+}
+
+// redactStrings replaces every non-empty string in place.
+func redactStrings(values []string) {
+	for i := range values {
+		if values[i] != "" {
+			values[i] = redacted
+		}
+	}
+}
+
+// redactValues replaces every non-empty map value in place, keeping the keys.
+func redactValues(values map[string]string) {
+	for key, value := range values {
+		if value != "" {
+			values[key] = redacted
+		}
+	}
+}
+
+// scheduling summarises the fields that decide where and whether a pod can run.
+func scheduling(spec *corev1.PodSpec) map[string]interface{} {
+	summary := map[string]interface{}{}
+	if spec.NodeName != "" {
+		summary["node_name"] = spec.NodeName
+	}
+	if len(spec.NodeSelector) > 0 {
+		summary["node_selector"] = spec.NodeSelector
+	}
+	if spec.PriorityClassName != "" {
+		summary["priority_class"] = spec.PriorityClassName
+	}
+	if spec.SchedulerName != "" && spec.SchedulerName != corev1.DefaultSchedulerName {
+		summary["scheduler"] = spec.SchedulerName
+	}
+
+	if a := spec.Affinity; a != nil {
+		var affinity []string
+		if a.NodeAffinity != nil {
+			affinity = append(affinity, "node affinity")
+		}
+		if a.PodAffinity != nil {
+			affinity = append(affinity, "pod affinity")
+		}
+		if a.PodAntiAffinity != nil {
+			affinity = append(affinity, "pod anti-affinity")
+		}
+		if len(affinity) > 0 {
+			summary["affinity"] = affinity
+		}
+	}
+	if len(spec.TopologySpreadConstraints) > 0 {
+		constraints := make([]string, len(spec.TopologySpreadConstraints))
+		for i, c := range spec.TopologySpreadConstraints {
+			constraints[i] = fmt.Sprintf("%s (max skew %d, %s)", c.TopologyKey, c.MaxSkew, c.WhenUnsatisfiable)
+		}
+		summary["topology_spread"] = constraints
+	}
+
+	if len(spec.Tolerations) > 0 {
+		tolerations := make([]string, len(spec.Tolerations))
+		for i, t := range spec.Tolerations {
+			tolerations[i] = toleration(t)
+		}
+		summary["tolerations"] = tolerations
+	}
+
+	requests := map[string]string{}
+	for _, c := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		if r := resourceList(c.Resources.Requests); r != "" {
+			requests[c.Name] = r
+		}
+	}
+	if len(requests) > 0 {
+		summary["requests"] = requests
+	}
+
+	if len(spec.Volumes) > 0 {
+		volumes := make([]string, len(spec.Volumes))
+		for i, v := range spec.Volumes {
+			volumes[i] = volume(v)
+		}
+		summary["volumes"] = volumes
+	}
+	return summary
+}
+
+// toleration formats a toleration like "dedicated=gpu:NoSchedule".
+func toleration(t corev1.Toleration) string {
+	s := t.Key
+	switch {
+	case t.Operator == corev1.TolerationOpExists && t.Key == "":
+		s = "*"
+	case t.Operator == corev1.TolerationOpExists:
+		s += " exists"
+	case t.Value != "":
+		s += "=" + t.Value
+	}
+	if t.Effect != "" {
+		s += ":" + string(t.Effect)
+	}
+	return s
+}
+
+// resourceList formats resources like "cpu=100m, memory=256Mi".
+func resourceList(resources corev1.ResourceList) string {
+	parts := make([]string, 0, len(resources))
+	for name, quantity := range resources {
+		parts = append(parts, string(name)+"="+quantity.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// volume formats a volume like "data (persistentVolumeClaim data-web-0)".
+func volume(v corev1.Volume) string {
+	switch {
+	case v.PersistentVolumeClaim != nil:
+		return fmt.Sprintf("%s (persistentVolumeClaim %s)", v.Name, v.PersistentVolumeClaim.ClaimName)
+	case v.ConfigMap != nil:
+		return fmt.Sprintf("%s (configMap %s)", v.Name, v.ConfigMap.Name)
+	case v.Secret != nil:
+		return fmt.Sprintf("%s (secret %s)", v.Name, v.Secret.SecretName)
+	case v.HostPath != nil:
+		return fmt.Sprintf("%s (hostPath %s)", v.Name, v.HostPath.Path)
+	case v.CSI != nil:
+		return fmt.Sprintf("%s (csi %s)", v.Name, v.CSI.Driver)
+	case v.EmptyDir != nil:
+		return v.Name + " (emptyDir)"
+	case v.Projected != nil:
+		return v.Name + " (projected)"
+	case v.Ephemeral != nil:
+		return v.Name + " (ephemeral)"
+	}
+	return v.Name
+}
+
+// IsSupportedKind returns true for kinds whose manifest can be fetched.
+func IsSupportedKind(kind string) bool {
+	switch kind {
+	case "Pod", "PersistentVolumeClaim", "ReplicaSet", "Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,193 @@
+package manifest
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func podRef(name string) corev1.ObjectReference {
+	return corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: name}
+}
+
+func newPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:     "default",
+			Name:          "web-7d9f8c6b5-abcde",
+			Annotations:   map[string]string{lastAppliedAnnotation: `{"password":"hunter2"}`, "team": "payments"},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply}},
+		},
+		Spec: corev1.PodSpec{
+			NodeSelector: map[string]string{"disktype": "ssd"},
+			Tolerations: []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "node.kubernetes.io/not-ready", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+			},
+			Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{}},
+			Containers: []corev1.Container{{
+				Name:    "app",
+				Command: []string{"/bin/web", "--db-password=hunter2"},
+				Args:    []string{"--token", "s3cr3t-token"},
+				LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+					Exec: &corev1.ExecAction{Command: []string{"check", "--auth=probe-secret"}},
+				}},
+				Env: []corev1.EnvVar{
+					{Name: "DATABASE_PASSWORD", Value: "hunter2"},
+					{Name: "API_KEY", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "api"}, Key: "key",
+					}}},
+				},
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("100m"),
+					corev1.ResourceMemory: resource.MustParse("256Mi"),
+				}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name:         "data",
+				VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "web-data"}},
+			}},
+		},
+	}
+}
+
+func TestFetcher_SanitisesPod(t *testing.T) {
+	pod := newPod()
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: "vault",
+		VolumeSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{
+			Driver:           "secrets-store.csi.k8s.io",
+			VolumeAttributes: map[string]string{"token": "csi-secret"},
+		}},
+	})
+	f := NewFetcher(fake.NewClientset(pod), time.Minute)
+
+	snapshot, err := f.Fetch(context.Background(), "default", podRef("web-7d9f8c6b5-abcde"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	manifest := string(snapshot.YAML)
+	for _, leaked := range []string{"hunter2", "s3cr3t-token", "probe-secret", "csi-secret", "payments", "managedFields", lastAppliedAnnotation} {
+		if strings.Contains(manifest, leaked) {
+			t.Errorf("expected %q to be stripped from:\n%s", leaked, manifest)
+		}
+	}
+	for _, kept := range []string{"kind: Pod", "apiVersion: v1", "team: '[redacted]'", "/bin/web", "DATABASE_PASSWORD", "secretKeyRef", "claimName: web-data", "token: '[redacted]'"} {
+		if !strings.Contains(manifest, kept) {
+			t.Errorf("expected %q in:\n%s", kept, manifest)
+		}
+	}
+}
+
+func TestFetcher_SanitisesPodTemplate(t *testing.T) {
+	pod := newPod()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"vault.example.com/token": "template-secret"}},
+			Spec:       pod.Spec,
+		}},
+	}
+	f := NewFetcher(fake.NewClientset(deployment), time.Minute)
+
+	snapshot, err := f.Fetch(context.Background(), "default", corev1.ObjectReference{Kind: "Deployment", Name: "web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	manifest := string(snapshot.YAML)
+	for _, leaked := range []string{"template-secret", "hunter2", "s3cr3t-token"} {
+		if strings.Contains(manifest, leaked) {
+			t.Errorf("expected %q to be stripped from:\n%s", leaked, manifest)
+		}
+	}
+	if !strings.Contains(manifest, "vault.example.com/token: '[redacted]'") {
+		t.Errorf("expected the template annotation key to be kept in:\n%s", manifest)
+	}
+}
+
+func TestFetcher_SummarisesScheduling(t *testing.T) {
+	f := NewFetcher(fake.NewClientset(newPod()), time.Minute)
+
+	snapshot, err := f.Fetch(context.Background(), "default", podRef("web-7d9f8c6b5-abcde"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := snapshot.Scheduling
+	if selector, _ := s["node_selector"].(map[string]string); selector["disktype"] != "ssd" {
+		t.Errorf("expected node selector, got %v", s["node_selector"])
+	}
+	want := []string{"dedicated=gpu:NoSchedule", "node.kubernetes.io/not-ready exists:NoExecute"}
+	if tolerations, _ := s["tolerations"].([]string); !slices.Equal(tolerations, want) {
+		t.Errorf("expected tolerations %v, got %v", want, s["tolerations"])
+	}
+	if affinity, _ := s["affinity"].([]string); !slices.Equal(affinity, []string{"pod anti-affinity"}) {
+		t.Errorf("expected pod anti-affinity, got %v", s["affinity"])
+	}
+	if requests, _ := s["requests"].(map[string]string); requests["app"] != "cpu=100m, memory=256Mi" {
+		t.Errorf("expected app requests, got %v", s["requests"])
+	}
+	if volumes, _ := s["volumes"].([]string); !slices.Equal(volumes, []string{"data (persistentVolumeClaim web-data)"}) {
+		t.Errorf("expected data volume, got %v", s["volumes"])
+	}
+}
+
+func TestFetcher_CachesSnapshots(t *testing.T) {
+	client := fake.NewClientset(newPod())
+	f := NewFetcher(client, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := f.Fetch(context.Background(), "default", podRef("web-7d9f8c6b5-abcde")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := len(client.Actions()); got != 1 {
+		t.Errorf("expected 1 API call, got %d", got)
+	}
+}
+
+func TestFetcher_CachesDenial(t *testing.T) {
+	client := fake.NewClientset(newPod())
+	client.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "web-7d9f8c6b5-abcde", nil)
+	})
+	f := NewFetcher(client, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := f.Fetch(context.Background(), "default", podRef("web-7d9f8c6b5-abcde"))
+		if !apierrors.IsForbidden(err) {
+			t.Fatalf("expected forbidden error, got %v", err)
+		}
+	}
+	if got := len(client.Actions()); got != 1 {
+		t.Errorf("expected the denial to be cached, got %d API calls", got)
+	}
+}
+
+func TestFetcher_UnsupportedKind(t *testing.T) {
+	client := fake.NewClientset()
+	f := NewFetcher(client, time.Minute)
+
+	ref := corev1.ObjectReference{Kind: "Secret", Namespace: "default", Name: "api"}
+	if _, err := f.Fetch(context.Background(), "default", ref); err == nil {
+		t.Error("expected error for Secret")
+	}
+	if got := len(client.Actions()); got != 0 {
+		t.Errorf("expected no API calls, got %d", got)
+	}
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kube_sentry_events"
+
+// registry holds all pipeline metrics plus the Go runtime and process collectors.
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+var (
+	// EventsReceived counts Kubernetes events delivered to the pipeline.
+	EventsReceived = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_received_total",
+		Help:      "Kubernetes events received by the watcher.",
+	})
+
+	// EventsFiltered counts events rejected by the filter, labelled by rejection reason
+	// (namespace, reason, type, namespace_labels, object_labels, opt_out).
+	EventsFiltered = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_filtered_total",
+		Help:      "Events rejected by the filter, by rejection reason.",
+	}, []string{"rejection"})
+
+	// EventsBelowThreshold counts events sent as logs only because their count is below threshold.
+	EventsBelowThreshold = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_below_threshold_total",
+		Help:      "Events whose Kubernetes count is below the issue threshold.",
+	})
+
+	// EventsDeduplicated counts events that met the threshold but were suppressed as duplicates.
+	EventsDeduplicated = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_deduplicated_total",
+		Help:      "Events suppressed by the deduplicator.",
+	})
+
+	// EventsMuted counts events meeting their threshold while muted by a mute-until annotation.
+	EventsMuted = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_muted_total",
+		Help:      "Events meeting their threshold while muted by a mute-until annotation.",
+	})
+
+	// IssuesFlapping counts issues created for events whose dedup window keeps reopening.
+	IssuesFlapping = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "issues_flapping_total",
+		Help:      "Issues created for flapping events.",
+	})
+
+	// RemindersSent counts follow-up events sent for issues that keep firing.
+	RemindersSent = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reminders_sent_total",
+		Help:      "Reminder events sent for ongoing issues.",
+	})
+
+	// BurstsAggregated counts bursts of events sent as a single aggregated issue.
+	BurstsAggregated = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bursts_aggregated_total",
+		Help:      "Bursts of events sent as a single aggregated issue.",
+	})
+
+	// PreviousLogs counts attempts to attach container logs to issues, by result.
+	PreviousLogs = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "previous_logs_total",
+		Help:      "Attempts to attach container logs to issues, by result (attached, failed).",
+	}, []string{"result"})
+
+	// Manifests counts attempts to attach the involved object's manifest to
+	// issues, by result.
+	Manifests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "manifests_total",
+		Help:      "Attempts to attach the involved object's manifest to issues, by result (attached, forbidden, failed).",
+	}, []string{"result"})
+
+	// IssuesSent counts Sentry issues captured.
+	IssuesSent = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "issues_sent_total",
+		Help:      "Sentry issues captured.",
+	})
+
+	// LogsSent counts Sentry log entries emitted.
+	LogsSent = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logs_sent_total",
+		Help:      "Sentry log entries emitted.",
+	})
+
+	// SendFailures counts issues the Sentry SDK refused to capture.
+	SendFailures = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_failures_total",
+		Help:      "Sentry issues that failed to be captured.",
+	})
+
+	// IssuesResolved counts issues closed because their workload recovered.
+	IssuesResolved = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "issues_resolved_total",
+		Help:      "Issues closed after the workload recovered.",
+	})
+
+	// ResolveFailures counts failed attempts to resolve an issue through the Sentry API.
+	ResolveFailures = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resolve_failures_total",
+		Help:      "Failed attempts to resolve an issue through the Sentry API.",
+	})
+
+	// WatchReconnects counts watch errors after which the informer reconnected.
+	WatchReconnects = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "watch_reconnects_total",
+		Help:      "Watch errors followed by a reconnect.",
+	})
+
+	// WatchConnected is 1 while the event watch is established and 0 while it reconnects.
+	WatchConnected = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watch_connected",
+		Help:      "Whether the event watch is established (1) or reconnecting (0).",
+	})
+
+	// SendLatency tracks the delay between Kubernetes last observing an event and us sending it.
+	SendLatency = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_send_latency_seconds",
+		Help:      "Time from the event's last observation in Kubernetes to sending it to Sentry.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900},
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RegisterDedupSize exposes the deduplicator cache size as a gauge.
+func RegisterDedupSize(size func() int) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "dedup_entries",
+		Help:      "Entries currently held by the deduplicator.",
+	}, func() float64 {
+		return float64(size())
+	})
+}
+
+// RegisterOpenIssues exposes the number of tracked open issues as a gauge.
+func RegisterOpenIssues(count func() int) {
+	factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_issues",
+		Help:      "Issues waiting for their workload to recover.",
+	}, func() float64 {
+		return float64(count())
+	})
+}
+
+// Handler returns the HTTP handler serving /metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_ExposesPipelineMetrics(t *testing.T) {
+	EventsReceived.Inc()
+	EventsFiltered.WithLabelValues("namespace").Inc()
+	WatchConnected.Set(1)
+	RegisterDedupSize(func() int { return 42 })
+	RegisterOpenIssues(func() int { return 3 })
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	output := string(body)
+
+	expected := []string{
+		"kube_sentry_events_events_received_total",
+		`kube_sentry_events_events_filtered_total{rejection="namespace"}`,
+		"kube_sentry_events_dedup_entries 42",
+		"kube_sentry_events_open_issues 3",
+		"kube_sentry_events_watch_connected 1",
+		"kube_sentry_events_event_send_latency_seconds_bucket",
+		"go_goroutines",
+	}
+	for _, name := range expected {
+		if !strings.Contains(output, name) {
+			t.Errorf("expected /metrics output to contain %q", name)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package recovery tracks Sentry issues that are still open so they can be
+// resolved when the workload recovers.
+package recovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imankulov/kube-sentry-events/internal/state"
+)
+
+const (
+	// maxIssues bounds the tracked issues, and so the saved state, to well
+	// under the 1MiB ConfigMap limit; the oldest issues are dropped first.
+	maxIssues = 1000
+
+	// maxIssueAge is how long an issue is tracked before it's given up on,
+	// e.g. a workload that stays broken or whose recovery can't be checked.
+	maxIssueAge = 7 * 24 * time.Hour
+)
+
+// Recoverable returns true for kinds whose recovery the watcher can check.
+// Issues for other kinds, e.g. custom resources, are never tracked.
+func Recoverable(kind string) bool {
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Pod", "Job", "CronJob", "Node":
+		return true
+	}
+	return false
+}
+
+// Issue is a Sentry issue created for a workload that hasn't recovered yet.
+type Issue struct {
+	Fingerprint []string `json:"fingerprint"`
+	Namespace   string   `json:"namespace,omitempty"` // empty for cluster-scoped objects
+	Kind        string   `json:"kind"`
+	Name        string   `json:"name"`
+	Reason      string   `json:"reason"`
+	// Project is the route the issue was sent to (empty for the default)
+	Project string `json:"project,omitempty"`
+	// EventID is the latest Sentry event sent for the issue; the Sentry API
+	// resolves it to the issue
+	EventID  string    `json:"eventID"`
+	OpenedAt time.Time `json:"openedAt"`
+}
+
+// Key identifies the issue by its fingerprint.
+func (i Issue) Key() string {
+	return Key(i.Fingerprint)
+}
+
+// Key returns the tracker key of a Sentry fingerprint.
+func Key(fingerprint []string) string {
+	return strings.Join(fingerprint, "/")
+}
+
+// Tracker records open issues in memory and persists them to a store.
+// All methods are safe to call on a nil Tracker, which disables tracking.
+type Tracker struct {
+	mu     sync.Mutex
+	store  state.Store // nil keeps issues in memory only
+	issues map[string]Issue
+	dirty  bool
+}
+
+// NewTracker creates a tracker persisting to store, which may be nil.
+func NewTracker(store state.Store) *Tracker {
+	return &Tracker{store: store, issues: make(map[string]Issue)}
+}
+
+// Open records an issue. If it is already open, only the event ID is updated
+// so the original opening time is kept.
+func (t *Tracker) Open(issue Issue) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if existing, ok := t.issues[issue.Key()]; ok {
+		issue.OpenedAt = existing.OpenedAt
+	}
+	t.issues[issue.Key()] = issue
+	t.dirty = true
+	t.trimLocked()
+}
+
+// Expire forgets issues opened more than maxIssueAge before now and returns
+// how many were dropped.
+func (t *Tracker) Expire(now time.Time) int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expired := 0
+	for key, issue := range t.issues {
+		if now.Sub(issue.OpenedAt) > maxIssueAge {
+			delete(t.issues, key)
+			expired++
+		}
+	}
+	if expired > 0 {
+		t.dirty = true
+	}
+	return expired
+}
+
+// trimLocked drops the oldest issues while over maxIssues. Must hold t.mu.
+func (t *Tracker) trimLocked() {
+	for len(t.issues) > maxIssues {
+		var oldest string
+		for key, issue := range t.issues {
+			if oldest == "" || issue.OpenedAt.Before(t.issues[oldest].OpenedAt) {
+				oldest = key
+			}
+		}
+		delete(t.issues, oldest)
+		t.dirty = true
+	}
+}
+
+// Get returns the open issue with the given key.
+func (t *Tracker) Get(key string) (Issue, bool) {
+	if t == nil {
+		return Issue{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	issue, ok := t.issues[key]
+	return issue, ok
+}
+
+// Close forgets an issue after it was resolved.
+func (t *Tracker) Close(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.issues[key]; ok {
+		delete(t.issues, key)
+		t.dirty = true
+	}
+}
+
+// Issues returns the open issues, oldest first.
+func (t *Tracker) Issues() []Issue {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	issues := make([]Issue, 0, len(t.issues))
+	for _, issue := range t.issues {
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].OpenedAt.Before(issues[j].OpenedAt)
+	})
+	return issues
+}
+
+// Load restores issues from the store. Issues opened since startup are kept.
+func (t *Tracker) Load(ctx context.Context) error {
+	if t == nil || t.store == nil {
+		return nil
+	}
+	data, err := t.store.Load(ctx)
+	if err != nil || data == nil {
+		return err
+	}
+
+	var saved []Issue
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to decode open issues: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, issue := range saved {
+		if _, ok := t.issues[issue.Key()]; !ok {
+			t.issues[issue.Key()] = issue
+		}
+	}
+	t.trimLocked()
+	return nil
+}
+
+// Save persists the issues if they changed since the last save.
+func (t *Tracker) Save(ctx context.Context) error {
+	if t == nil || t.store == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	if !t.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	t.dirty = false
+	t.mu.Unlock()
+
+	data, err := json.Marshal(t.Issues())
+	if err == nil {
+		err = t.store.Save(ctx, data)
+	}
+	if err != nil {
+		// Retry on the next save
+		t.mu.Lock()
+		t.dirty = true
+		t.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Len returns the number of open issues.
+func (t *Tracker) Len() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.issues)
+}
@@ -0,0 +1,159 @@
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory state.Store.
+type memoryStore struct {
+	data  []byte
+	saves int
+	err   error
+}
+
+func (m *memoryStore) Load(_ context.Context) ([]byte, error) {
+	return m.data, nil
+}
+
+func (m *memoryStore) Save(_ context.Context, data []byte) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.data = data
+	m.saves++
+	return nil
+}
+
+func newIssue(name string, openedAt time.Time) Issue {
+	return Issue{
+		Fingerprint: []string{"k8s", "default", "Deployment", name, "CrashLoopBackOff"},
+		Namespace:   "default",
+		Kind:        "Deployment",
+		Name:        name,
+		Reason:      "CrashLoopBackOff",
+		EventID:     "event-" + name,
+		OpenedAt:    openedAt,
+	}
+}
+
+func TestTracker_OpenKeepsOpenedAt(t *testing.T) {
+	tracker := NewTracker(nil)
+	opened := time.Now().Add(-time.Hour)
+
+	tracker.Open(newIssue("web", opened))
+	later := newIssue("web", time.Now())
+	later.EventID = "event-2"
+	tracker.Open(later)
+
+	issue, ok := tracker.Get(later.Key())
+	if !ok {
+		t.Fatal("expected issue to be open")
+	}
+	if !issue.OpenedAt.Equal(opened) || issue.EventID != "event-2" {
+		t.Errorf("expected original open time and latest event, got %v and %s", issue.OpenedAt, issue.EventID)
+	}
+
+	tracker.Close(later.Key())
+	if tracker.Len() != 0 {
+		t.Errorf("expected issue to be closed, got %d open", tracker.Len())
+	}
+}
+
+func TestTracker_BoundsIssues(t *testing.T) {
+	tracker := NewTracker(nil)
+	start := time.Now().Add(-time.Hour)
+
+	for i := 0; i < maxIssues+2; i++ {
+		tracker.Open(newIssue(fmt.Sprint("web-", i), start.Add(time.Duration(i)*time.Second)))
+	}
+
+	if tracker.Len() != maxIssues {
+		t.Errorf("expected %d issues, got %d", maxIssues, tracker.Len())
+	}
+	for i, want := range []bool{false, false, true} {
+		if _, ok := tracker.Get(newIssue(fmt.Sprint("web-", i), start).Key()); ok != want {
+			t.Errorf("web-%d: expected tracked %v, got %v", i, want, ok)
+		}
+	}
+}
+
+func TestTracker_Expire(t *testing.T) {
+	store := &memoryStore{}
+	tracker := NewTracker(store)
+	now := time.Now()
+
+	tracker.Open(newIssue("stale", now.Add(-maxIssueAge-time.Minute)))
+	tracker.Open(newIssue("recent", now.Add(-maxIssueAge+time.Minute)))
+	if err := tracker.Save(context.Background()); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	if expired := tracker.Expire(now); expired != 1 {
+		t.Errorf("expected 1 expired issue, got %d", expired)
+	}
+	if _, ok := tracker.Get(newIssue("recent", now).Key()); !ok || tracker.Len() != 1 {
+		t.Errorf("expected only the recent issue to be kept, got %v", tracker.Issues())
+	}
+
+	// The expiry is saved
+	if err := tracker.Save(context.Background()); err != nil || store.saves != 2 {
+		t.Errorf("expected expiry to be saved, got %d saves (%v)", store.saves, err)
+	}
+}
+
+func TestTracker_SurvivesRestart(t *testing.T) {
+	store := &memoryStore{}
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tracker := NewTracker(store)
+	tracker.Open(newIssue("web", now.Add(-time.Minute)))
+	tracker.Open(newIssue("api", now))
+	if err := tracker.Save(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tracker.Save(ctx); err != nil || store.saves != 1 {
+		t.Errorf("expected unchanged issues not to be saved again, got %d saves (%v)", store.saves, err)
+	}
+
+	restarted := NewTracker(store)
+	restarted.Open(newIssue("worker", now))
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	issues := restarted.Issues()
+	if len(issues) != 3 || issues[0].Name != "web" || !issues[0].OpenedAt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("expected restored issues oldest first, got %+v", issues)
+	}
+}
+
+func TestTracker_RetriesFailedSave(t *testing.T) {
+	store := &memoryStore{err: errors.New("conflict")}
+	tracker := NewTracker(store)
+	tracker.Open(newIssue("web", time.Now()))
+
+	if err := tracker.Save(context.Background()); err == nil {
+		t.Fatal("expected save error")
+	}
+	store.err = nil
+	if err := tracker.Save(context.Background()); err != nil || store.saves != 1 {
+		t.Errorf("expected failed save to be retried, got %d saves (%v)", store.saves, err)
+	}
+}
+
+func TestTracker_Nil(t *testing.T) {
+	var tracker *Tracker
+	tracker.Open(newIssue("web", time.Now()))
+	tracker.Close("key")
+	if tracker.Len() != 0 || tracker.Issues() != nil {
+		t.Error("expected nil tracker to track nothing")
+	}
+	if err := tracker.Save(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
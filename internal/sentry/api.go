@@ -0,0 +1,96 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the Sentry SaaS URL; self-hosted installs use their own.
+const DefaultAPIURL = "https://sentry.io"
+
+// ErrEventNotFound is returned when Sentry doesn't know an event, e.g. because
+// it was dropped by rate limiting or has been deleted.
+var ErrEventNotFound = errors.New("sentry event not found")
+
+// APIClient resolves issues through the Sentry Web API using an auth token
+// with the event:write scope.
+type APIClient struct {
+	baseURL    string
+	org        string
+	token      string
+	httpClient *http.Client
+}
+
+// NewAPIClient creates a client for the organization at baseURL
+// (DefaultAPIURL if empty).
+func NewAPIClient(baseURL, org, token string) *APIClient {
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	return &APIClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		org:        org,
+		token:      token,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ResolveEvent resolves the issue the event was grouped into.
+func (c *APIClient) ResolveEvent(ctx context.Context, eventID string) error {
+	var event struct {
+		GroupID string `json:"groupId"`
+	}
+	path := fmt.Sprintf("/api/0/organizations/%s/eventids/%s/", url.PathEscape(c.org), url.PathEscape(eventID))
+	if err := c.do(ctx, http.MethodGet, path, nil, &event); err != nil {
+		return err
+	}
+	if event.GroupID == "" {
+		return fmt.Errorf("sentry event %s has no issue", eventID)
+	}
+
+	path = fmt.Sprintf("/api/0/issues/%s/", url.PathEscape(event.GroupID))
+	return c.do(ctx, http.MethodPut, path, map[string]string{"status": "resolved"}, nil)
+}
+
+// do sends a JSON request and decodes the response into out, if not nil.
+func (c *APIClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, &reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentry API %s %s: %w", method, path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("sentry API %s %s: %w", method, path, ErrEventNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sentry API %s %s: unexpected status %s", method, path, resp.Status)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("sentry API %s %s: failed to decode response: %w", method, path, err)
+		}
+	}
+	return nil
+}
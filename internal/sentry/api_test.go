@@ -0,0 +1,60 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIClient_ResolveEvent(t *testing.T) {
+	var resolved map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/0/organizations/acme/eventids/abc123/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"organizationSlug":"acme","projectSlug":"k8s","groupId":"42","eventId":"abc123"}`))
+	})
+	mux.HandleFunc("PUT /api/0/issues/42/", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&resolved); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"resolved"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewAPIClient(server.URL+"/", "acme", "secret")
+	if err := client.ResolveEvent(context.Background(), "abc123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resolved["status"] != "resolved" {
+		t.Errorf("expected issue to be resolved, got %v", resolved)
+	}
+}
+
+func TestAPIClient_ResolveEventErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/0/organizations/acme/eventids/missing/", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /api/0/organizations/acme/eventids/forbidden/", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, "acme", "secret")
+	if err := client.ResolveEvent(context.Background(), "missing"); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("expected ErrEventNotFound, got %v", err)
+	}
+	err := client.ResolveEvent(context.Background(), "forbidden")
+	if err == nil || errors.Is(err, ErrEventNotFound) {
+		t.Errorf("expected a non-404 error, got %v", err)
+	}
+}
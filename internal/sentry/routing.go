@@ -0,0 +1,102 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Route sends events from matching namespaces to a separate Sentry project.
+type Route struct {
+	// Name identifies the route; events annotated with this dsn-project use it
+	Name string
+	DSN  string
+	// Namespaces and NamespaceSelector match events by namespace name or labels
+	Namespaces        []string
+	NamespaceSelector string
+}
+
+// destination is a Sentry client with its own hub, one per route plus the default.
+type destination struct {
+	name       string
+	hub        *sentry.Hub
+	logger     sentry.Logger // nil unless logs are enabled
+	namespaces map[string]struct{}
+	selector   labels.Selector // nil if not configured
+}
+
+func newDestination(name, dsn, environment string, enableLogs bool) (*destination, error) {
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:              dsn,
+		Environment:      environment,
+		EnableLogs:       enableLogs,
+		AttachStacktrace: false,
+		// Release can be set via SENTRY_RELEASE env var
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Sentry client %q: %w", name, err)
+	}
+
+	d := &destination{
+		name: name,
+		hub:  sentry.NewHub(client, sentry.NewScope()),
+	}
+	if enableLogs {
+		d.logger = sentry.NewLogger(sentry.SetHubOnContext(context.Background(), d.hub))
+	}
+	return d, nil
+}
+
+// matches returns true if the route covers the namespace.
+func (d *destination) matches(namespace string, namespaceLabels map[string]string) bool {
+	if _, ok := d.namespaces[namespace]; ok {
+		return true
+	}
+	return d.selector != nil && d.selector.Matches(labels.Set(namespaceLabels))
+}
+
+// destinationFor picks where an event goes: the route named by the dsn-project
+// annotation, then the first route matching the namespace, then the default.
+func (s *Sender) destinationFor(data EventData, namespace string) *destination {
+	if data.Project != "" {
+		for _, d := range s.routes {
+			if d.name == data.Project {
+				return d
+			}
+		}
+	}
+	for _, d := range s.routes {
+		if d.matches(namespace, data.NamespaceLabels) {
+			return d
+		}
+	}
+	return s.fallback
+}
+
+// Flush waits for events on every destination to be sent, in parallel.
+// It returns false if any destination timed out.
+func (s *Sender) Flush(timeout time.Duration) bool {
+	destinations := append([]*destination{s.fallback}, s.routes...)
+
+	var wg sync.WaitGroup
+	results := make([]bool, len(destinations))
+	for i, d := range destinations {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = d.hub.Flush(timeout)
+		}()
+	}
+	wg.Wait()
+
+	for _, ok := range results {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
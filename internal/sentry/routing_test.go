@@ -0,0 +1,115 @@
+package sentry
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/recovery"
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+func newTestRoutedSender(t *testing.T) *Sender {
+	t.Helper()
+	s, err := New("https://default@example.com/1", "test", false, []Route{
+		{Name: "payments", DSN: "https://payments@example.com/2", Namespaces: []string{"payments", "billing"}},
+		{Name: "data", DSN: "https://data@example.com/3", NamespaceSelector: "team=data"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestSender_DestinationFor(t *testing.T) {
+	s := newTestRoutedSender(t)
+
+	tests := []struct {
+		name      string
+		data      EventData
+		namespace string
+		expected  string
+	}{
+		{"namespace list", EventData{}, "billing", "payments"},
+		{"namespace selector", EventData{NamespaceLabels: map[string]string{"team": "data"}}, "etl", "data"},
+		{"annotation wins", EventData{Project: "data"}, "payments", "data"},
+		{"unknown annotation falls through", EventData{Project: "nope"}, "payments", "payments"},
+		{"unmatched", EventData{}, "default", "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.destinationFor(tt.data, tt.namespace).name; got != tt.expected {
+				t.Errorf("destinationFor() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSender_DestinationsHaveSeparateClients(t *testing.T) {
+	s := newTestRoutedSender(t)
+
+	if s.fallback.hub.Client() == s.routes[0].hub.Client() {
+		t.Error("expected each route to have its own client")
+	}
+	if got := s.routes[0].hub.Client().Options().Dsn; got != "https://payments@example.com/2" {
+		t.Errorf("unexpected route DSN %s", got)
+	}
+	if !s.Flush(time.Second) {
+		t.Error("expected flush with nothing queued to succeed")
+	}
+}
+
+func TestNew_InvalidRoute(t *testing.T) {
+	if _, err := New("https://default@example.com/1", "test", false, []Route{
+		{Name: "bad", DSN: "https://bad@example.com/2", NamespaceSelector: "team in data"},
+	}); err == nil {
+		t.Error("expected error for invalid selector")
+	}
+	if _, err := New("https://default@example.com/1", "test", false, []Route{
+		{Name: "bad", DSN: "not a dsn"},
+	}); err == nil {
+		t.Error("expected error for invalid DSN")
+	}
+}
+
+func TestSender_TracksIssues(t *testing.T) {
+	// Empty DSNs capture events without sending them anywhere
+	s, err := New("", "test", false, []Route{{Name: "payments", Namespaces: []string{"payments"}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tracker := recovery.NewTracker(nil)
+	s.SetTracker(tracker)
+
+	for _, namespace := range []string{"payments", "default"} {
+		s.Send(EventData{
+			Event: &corev1.Event{
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: "web-7d9f8c6b5-abcde"},
+				Reason:         "CrashLoopBackOff",
+			},
+			MeetsThreshold: true,
+			Workload:       workload.Workload{Kind: "Deployment", Name: "web"},
+		})
+	}
+	s.Send(EventData{
+		Event:    &corev1.Event{InvolvedObject: corev1.ObjectReference{Kind: "Node", Name: "node-1"}, Reason: "NodeNotReady"},
+		Resolved: true,
+	})
+
+	issues := tracker.Issues()
+	if len(issues) != 2 {
+		t.Fatalf("expected one tracked issue per fingerprint, got %+v", issues)
+	}
+	projects := map[string]string{}
+	for _, issue := range issues {
+		if issue.EventID == "" || issue.Kind != "Deployment" || issue.Name != "web" {
+			t.Errorf("unexpected issue %+v", issue)
+		}
+		projects[issue.Namespace] = issue.Project
+	}
+	if projects["payments"] != "payments" || projects["default"] != "" {
+		t.Errorf("expected route names to be recorded, got %v", projects)
+	}
+}
@@ -0,0 +1,86 @@
+package sentry
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fakeTransport records captured events instead of sending them.
+type fakeTransport struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (t *fakeTransport) Configure(sentry.ClientOptions)        {}
+func (t *fakeTransport) Flush(time.Duration) bool              { return true }
+func (t *fakeTransport) FlushWithContext(context.Context) bool { return true }
+func (t *fakeTransport) Close()                                {}
+func (t *fakeTransport) SendEvent(event *sentry.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, event)
+}
+
+func (t *fakeTransport) captured() []*sentry.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.events
+}
+
+func newFakeTransportSender(t *testing.T) (*Sender, *fakeTransport) {
+	t.Helper()
+	transport := &fakeTransport{}
+	client, err := sentry.NewClient(sentry.ClientOptions{
+		Dsn:       "https://key@example.com/1",
+		Transport: transport,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	dest := &destination{name: "default", hub: sentry.NewHub(client, sentry.NewScope())}
+	return &Sender{environment: "test", fallback: dest}, transport
+}
+
+func TestSender_NoBreadcrumbBleed(t *testing.T) {
+	s, transport := newFakeTransportSender(t)
+
+	for _, pod := range []string{"api-7d9f8c6b5-abcde", "worker-5c6d7e8f9-fghij", "web-6b7c8d9e0-klmno"} {
+		s.Send(EventData{
+			Event: &corev1.Event{
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: pod},
+				Reason:         "OOMKilled",
+			},
+			Severity:       "error",
+			MeetsThreshold: true,
+		})
+	}
+
+	events := transport.captured()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 captured events, got %d", len(events))
+	}
+	for _, event := range events {
+		pod := event.Tags["k8s.pod"]
+		if len(event.Breadcrumbs) != 3 {
+			t.Errorf("expected 3 breadcrumbs for %s, got %d", pod, len(event.Breadcrumbs))
+		}
+		for _, crumb := range event.Breadcrumbs {
+			if !strings.Contains(crumb.Message, pod) {
+				t.Errorf("breadcrumb %q for %s refers to another pod", crumb.Message, pod)
+			}
+		}
+	}
+
+	// Anything else captured on the destination hub carries none of them
+	s.fallback.hub.CaptureMessage("unrelated")
+	events = transport.captured()
+	if crumbs := events[len(events)-1].Breadcrumbs; len(crumbs) != 0 {
+		t.Errorf("expected shared scope to have no breadcrumbs, got %d", len(crumbs))
+	}
+}
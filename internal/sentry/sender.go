@@ -1,7 +1,6 @@
 package sentry
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +10,11 @@ import (
 	"github.com/getsentry/sentry-go"
 	"github.com/getsentry/sentry-go/attribute"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/recovery"
+	"github.com/imankulov/kube-sentry-events/internal/workload"
 )
 
 // EventData contains processed event information for Sentry.
@@ -21,38 +25,312 @@ type EventData struct {
 	FirstSeen      time.Time
 	LastSeen       time.Time
 	MeetsThreshold bool // Whether this event should create an Issue
+	// Workload owning the involved object; guessed from the pod name if empty
+	Workload workload.Workload
+	// Project is the Sentry project requested by annotation (empty for the default)
+	Project string
+	// NamespaceLabels are used to match routes by namespace selector
+	NamespaceLabels map[string]string
+	// Overrides lists annotation overrides applied to the global rules, e.g.
+	// "severity" -> "error (Deployment/web)"
+	Overrides map[string]string
+	// Container is the termination for events synthesised from Pod status, or
+	// the involved container as cached for kubelet Events
+	Container *ContainerTermination
+	// Node is set for events synthesised from Node conditions
+	Node *NodeCondition
+	// Job is set for failed Jobs
+	Job *JobFailure
+	// Resolved marks a log-only notification that the problem has cleared
+	Resolved bool
+	// Flapping marks an event whose dedup window keeps reopening; Recurrences
+	// is how many windows opened within the flapping period
+	Flapping    bool
+	Recurrences int
+	// Reminder marks a follow-up issue event for a problem that kept firing
+	// since it was last sent
+	Reminder bool
+	// Aggregate is set for an issue summarising a burst of events
+	Aggregate *Aggregate
+	// Logs are the crashed container's logs, attached to the issue if set
+	Logs *ContainerLogs
+	// Manifest is the involved object's sanitised manifest, attached to the
+	// issue if set
+	Manifest *Manifest
+}
+
+// Manifest is a snapshot of the object an event is about.
+type Manifest struct {
+	Kind string
+	Name string
+	YAML []byte
+	// Scheduling summarises the pod spec's scheduling constraints, if any
+	Scheduling map[string]interface{}
+}
+
+// attachment returns the manifest as a Sentry attachment.
+func (m *Manifest) attachment() *sentry.Attachment {
+	return &sentry.Attachment{
+		Filename:    strings.ToLower(m.Kind) + "-" + m.Name + ".yaml",
+		ContentType: "application/yaml",
+		Payload:     m.YAML,
+	}
+}
+
+// ContainerLogs are the last lines a container logged before it exited.
+type ContainerLogs struct {
+	Container string
+	Previous  bool // Whether these are the logs of the previous instance
+	Output    []byte
+	Truncated bool // Whether earlier output was dropped to fit the size budget
+}
+
+// attachment returns the logs as a Sentry attachment named after the pod.
+func (l *ContainerLogs) attachment(podName string) *sentry.Attachment {
+	payload := l.Output
+	if l.Truncated {
+		payload = append([]byte("[truncated]\n"), payload...)
+	}
+	name := podName
+	if l.Container != "" {
+		name += "-" + l.Container
+	}
+	if l.Previous {
+		name += "-previous"
+	}
+	return &sentry.Attachment{
+		Filename:    name + ".log",
+		ContentType: "text/plain",
+		Payload:     payload,
+	}
+}
+
+// Aggregate summarises many workloads hitting the same reason on a node or in
+// a namespace at once.
+type Aggregate struct {
+	Node      string   // Empty if grouped by namespace
+	Namespace string   // Empty if grouped by node
+	Workloads []string // namespace/Kind/name of each affected workload
+	Events    int
+}
+
+// extra returns the burst details as Sentry extra fields.
+func (a *Aggregate) extra() map[string]interface{} {
+	return map[string]interface{}{
+		"affected_workloads":      a.Workloads,
+		"affected_workload_count": len(a.Workloads),
+		"aggregated_events":       a.Events,
+	}
+}
+
+// JobFailure describes a failed Job and its most recently failed container.
+type JobFailure struct {
+	Job     string
+	CronJob string // Empty if the Job wasn't created by a CronJob
+	Reason  string // Reason of the Failed condition, e.g. BackoffLimitExceeded
+	Message string
+	// Pod, Container, ExitCode and TerminationMessage are empty if no failed
+	// container was found (e.g. pods already garbage collected)
+	Pod                string
+	Container          string
+	ExitCode           int32
+	TerminationMessage string
+}
+
+// extra returns the failure details as Sentry extra fields.
+func (j *JobFailure) extra() map[string]interface{} {
+	extra := map[string]interface{}{
+		"job":                 j.Job,
+		"job_failure_reason":  j.Reason,
+		"job_failure_message": j.Message,
+	}
+	if j.CronJob != "" {
+		extra["cronjob"] = j.CronJob
+	}
+	if j.Pod != "" {
+		extra["failed_pod"] = j.Pod
+		extra["failed_container"] = j.Container
+		extra["exit_code"] = j.ExitCode
+		extra["termination_message"] = j.TerminationMessage
+	}
+	return extra
+}
+
+// NodeCondition describes a Node condition transition.
+type NodeCondition struct {
+	Type        string // e.g. Ready, MemoryPressure
+	Status      string
+	Reason      string
+	Message     string
+	Capacity    map[string]string
+	Allocatable map[string]string
+	Pods        []string // namespace/name of pods scheduled on the node
+}
+
+// extra returns the condition details as Sentry extra fields.
+func (n *NodeCondition) extra() map[string]interface{} {
+	return map[string]interface{}{
+		"node_condition":        n.Type,
+		"node_condition_status": n.Status,
+		"node_condition_reason": n.Reason,
+		"capacity":              n.Capacity,
+		"allocatable":           n.Allocatable,
+		"affected_pods":         n.Pods,
+	}
+}
+
+// ContainerTermination describes a container exit detected from Pod status, or
+// a container's last exit, if any, for kubelet Events.
+type ContainerTermination struct {
+	Name         string
+	Image        string
+	Reason       string // Kubelet's terminated reason, e.g. OOMKilled or Error
+	ExitCode     int32
+	Signal       int32
+	RestartCount int32
+	MemoryLimit  string // Empty if the container has no memory limit
+	// Resources are the requests and limits, e.g. "limits.memory" -> "256Mi"
+	Resources map[string]string
+}
+
+// terminated reports whether the container has exited; a container that
+// hasn't, e.g. one waiting on an image pull, has no exit code to report.
+func (c *ContainerTermination) terminated() bool {
+	return c.Reason != "" || c.ExitCode != 0
+}
+
+// context returns the termination details as the Sentry container context.
+func (c *ContainerTermination) context() sentry.Context {
+	ctx := sentry.Context{
+		"name":          c.Name,
+		"image":         c.Image,
+		"restart_count": c.RestartCount,
+	}
+	if c.terminated() {
+		ctx["termination_reason"] = c.Reason
+		ctx["exit_code"] = c.ExitCode
+	}
+	if c.Signal != 0 {
+		ctx["signal"] = c.Signal
+	}
+	if c.MemoryLimit != "" {
+		ctx["memory_limit"] = c.MemoryLimit
+	}
+	if len(c.Resources) > 0 {
+		ctx["resources"] = c.Resources
+	}
+	return ctx
+}
+
+// imageTag returns the tag of a container image reference, e.g. "v1.4.2" for
+// "registry:5000/app:v1.4.2@sha256:...". It's empty for untagged and "latest"
+// images, which say nothing about the deployed version.
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	if tag := image[i+1:]; tag != "latest" {
+		return tag
+	}
+	return ""
 }
 
-// Sender sends Kubernetes events to Sentry.
+// issueContexts returns the structured Sentry contexts of an issue: the
+// Kubernetes object, the troubleshooting guidance, and the container and
+// scheduling constraints if known.
+func issueContexts(data EventData, namespace, nodeName string, wl workload.Workload, troubleshooting TroubleshootingContext) map[string]sentry.Context {
+	ref := data.Event.InvolvedObject
+	k8s := sentry.Context{
+		"namespace":     namespace,
+		"kind":          ref.Kind,
+		"name":          ref.Name,
+		"workload_kind": wl.Kind,
+		"workload":      wl.Name,
+	}
+	if ref.Kind == "Pod" {
+		k8s["pod"] = ref.Name
+	}
+	if nodeName != "" {
+		k8s["node"] = nodeName
+	}
+	if len(wl.Chain) > 1 {
+		k8s["owner_chain"] = wl.ChainNames()
+	}
+
+	contexts := map[string]sentry.Context{
+		"kubernetes": k8s,
+		"troubleshooting": {
+			"description":    troubleshooting.Description,
+			"likely_causes":  troubleshooting.LikelyCauses,
+			"debug_commands": troubleshooting.DebugCommands,
+			"runbook_url":    troubleshooting.RunbookURL,
+		},
+	}
+	if data.Container != nil {
+		contexts["container"] = data.Container.context()
+	}
+	if data.Manifest != nil && len(data.Manifest.Scheduling) > 0 {
+		contexts["scheduling"] = data.Manifest.Scheduling
+	}
+	return contexts
+}
+
+// workload returns the resolved workload, falling back to pod-name heuristics.
+func (d EventData) workload() workload.Workload {
+	if d.Workload.Name != "" {
+		return d.Workload
+	}
+	return GuessWorkload(d.Event.InvolvedObject)
+}
+
+// Sender sends Kubernetes events to Sentry. Each route has its own client and
+// hub; events not matching any route go to the default DSN.
 type Sender struct {
 	environment string
 	enableLogs  bool
-	logger      sentry.Logger
+	fallback    *destination
+	routes      []*destination
+
+	guidance
+
+	// tracker records created issues so they can be resolved on recovery (nil disables it)
+	tracker *recovery.Tracker
 }
 
-// New creates a new Sentry sender.
-func New(dsn, environment string, enableLogs bool) (*Sender, error) {
-	err := sentry.Init(sentry.ClientOptions{
-		Dsn:              dsn,
-		Environment:      environment,
-		EnableLogs:       enableLogs,
-		AttachStacktrace: false,
-		// Release can be set via SENTRY_RELEASE env var
-	})
+// New creates a new Sentry sender with dsn as the default destination and an
+// optional routing table evaluated in order.
+func New(dsn, environment string, enableLogs bool, routes []Route) (*Sender, error) {
+	fallback, err := newDestination("default", dsn, environment, enableLogs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Sentry: %w", err)
+		return nil, err
 	}
 
-	var logger sentry.Logger
-	if enableLogs {
-		logger = sentry.NewLogger(context.Background())
-	}
-
-	return &Sender{
+	s := &Sender{
 		environment: environment,
 		enableLogs:  enableLogs,
-		logger:      logger,
-	}, nil
+		fallback:    fallback,
+	}
+
+	for _, route := range routes {
+		d, err := newDestination(route.Name, route.DSN, environment, enableLogs)
+		if err != nil {
+			return nil, err
+		}
+		d.namespaces = make(map[string]struct{}, len(route.Namespaces))
+		for _, ns := range route.Namespaces {
+			d.namespaces[ns] = struct{}{}
+		}
+		if route.NamespaceSelector != "" {
+			if d.selector, err = labels.Parse(route.NamespaceSelector); err != nil {
+				return nil, fmt.Errorf("invalid namespace selector for route %q: %w", route.Name, err)
+			}
+		}
+		s.routes = append(s.routes, d)
+	}
+
+	return s, nil
 }
 
 // Send sends a Kubernetes event to Sentry.
@@ -70,32 +348,33 @@ func (s *Sender) Send(data EventData) {
 	nodeName := event.Source.Host
 	reason := event.Reason
 	kind := event.InvolvedObject.Kind
-	deployment := ExtractDeploymentName(podName)
+	wl := data.workload()
+	dest := s.destinationFor(data, namespace)
 
 	// Always send to Sentry Logs if enabled (for observability)
 	if s.enableLogs {
-		s.sendLog(data, namespace, podName, nodeName, reason, kind, deployment)
+		s.sendLog(dest, data, namespace, podName, nodeName, reason, kind, wl)
 	}
 
 	// Only create Issue if event meets threshold (for alerting)
-	if data.MeetsThreshold {
-		s.sendIssue(data, namespace, podName, nodeName, reason, kind, deployment)
+	if data.MeetsThreshold && !data.Resolved {
+		s.sendIssue(dest, data, namespace, podName, nodeName, reason, kind, wl)
 	}
 }
 
 // sendLog sends the event to Sentry Logs for observability.
-func (s *Sender) sendLog(data EventData, namespace, podName, nodeName, reason, kind, deployment string) {
+func (s *Sender) sendLog(dest *destination, data EventData, namespace, podName, nodeName, reason, kind string, wl workload.Workload) {
 	event := data.Event
 
 	// Map Sentry Level to Log Level
 	var logEntry sentry.LogEntry
 	switch data.Severity {
 	case sentry.LevelError, sentry.LevelFatal:
-		logEntry = s.logger.Error()
+		logEntry = dest.logger.Error()
 	case sentry.LevelWarning:
-		logEntry = s.logger.Warn()
+		logEntry = dest.logger.Warn()
 	default:
-		logEntry = s.logger.Info()
+		logEntry = dest.logger.Info()
 	}
 
 	// Add attributes for searchability
@@ -104,58 +383,116 @@ func (s *Sender) sendLog(data EventData, namespace, podName, nodeName, reason, k
 		String("k8s.pod", podName).
 		String("k8s.reason", reason).
 		String("k8s.kind", kind).
-		String("k8s.deployment", deployment).
+		String("k8s.deployment", wl.Name).
+		String("k8s.workload_kind", wl.Kind).
 		Int("k8s.event_count", int(event.Count))
 
 	if nodeName != "" {
 		logEntry = logEntry.String("k8s.node", nodeName)
 	}
+	if data.Resolved {
+		logEntry = logEntry.Bool("k8s.resolved", true)
+	}
+	if data.Flapping {
+		logEntry = logEntry.Bool("k8s.flapping", true)
+	}
+	if data.Container != nil {
+		logEntry = logEntry.
+			String("k8s.container", data.Container.Name).
+			Int("k8s.restart_count", int(data.Container.RestartCount))
+		if data.Container.terminated() {
+			logEntry = logEntry.Int("k8s.exit_code", int(data.Container.ExitCode))
+		}
+	}
+	if data.Job != nil && data.Job.CronJob != "" {
+		logEntry = logEntry.String("k8s.cronjob", data.Job.CronJob)
+	}
 
 	// Emit the log
 	logEntry.Emitf("[%s] %s: %s - %s", namespace, reason, podName, event.Message)
+	metrics.LogsSent.Inc()
 }
 
 // sendIssue creates a Sentry Issue for critical events.
-func (s *Sender) sendIssue(data EventData, namespace, podName, nodeName, reason, kind, deployment string) {
+func (s *Sender) sendIssue(dest *destination, data EventData, namespace, podName, nodeName, reason, kind string, wl workload.Workload) {
 	event := data.Event
 
 	// Build message
 	message := fmt.Sprintf("%s: %s", reason, podName)
 
-	// Get troubleshooting context
-	troubleshooting := getTroubleshootingContext(reason)
+	// Get troubleshooting context, filled in with the event's names
+	troubleshooting := s.troubleshootingFor(reason, namespace).render(templateData(data, namespace, nodeName, wl))
 
 	// Create Sentry event
 	sentryEvent := &sentry.Event{
 		Message: message,
 		Level:   data.Severity,
 		Tags: map[string]string{
-			"k8s.namespace": namespace,
-			"k8s.pod":       podName,
-			"k8s.reason":    reason,
-			"k8s.kind":      kind,
+			"k8s.namespace":     namespace,
+			"k8s.pod":           podName,
+			"k8s.reason":        reason,
+			"k8s.kind":          kind,
+			"k8s.workload_kind": wl.Kind,
 		},
 		Extra: map[string]interface{}{
 			"message":    event.Message,
 			"count":      data.Count,
 			"first_seen": data.FirstSeen.UTC().Format(time.RFC3339),
 			"last_seen":  data.LastSeen.UTC().Format(time.RFC3339),
-			// Troubleshooting guidance
-			"description":    troubleshooting.Description,
-			"likely_causes":  troubleshooting.LikelyCauses,
-			"debug_commands": troubleshooting.DebugCommands,
-			"runbook_url":    troubleshooting.RunbookURL,
 		},
+		Contexts: issueContexts(data, namespace, nodeName, wl, troubleshooting),
 		// Fingerprint groups related events together
-		Fingerprint: []string{"k8s", namespace, deployment, reason},
+		Fingerprint: Fingerprint(namespace, wl, reason),
 	}
 
 	// Add optional tags
 	if nodeName != "" {
 		sentryEvent.Tags["k8s.node"] = nodeName
 	}
-	if deployment != "" && deployment != podName {
-		sentryEvent.Tags["k8s.deployment"] = deployment
+	if wl.Name != "" && wl.Name != podName {
+		sentryEvent.Tags["k8s.deployment"] = wl.Name
+	}
+	if data.Project != "" {
+		sentryEvent.Tags["k8s.project"] = data.Project
+	}
+	if data.Flapping {
+		sentryEvent.Tags["k8s.flapping"] = "true"
+		sentryEvent.Extra["recurrences"] = data.Recurrences
+	}
+	if data.Reminder {
+		sentryEvent.Tags["k8s.reminder"] = "true"
+		sentryEvent.Extra["duration"] = data.LastSeen.Sub(data.FirstSeen).Round(time.Second).String()
+	}
+	if data.Aggregate != nil {
+		sentryEvent.Tags["k8s.aggregated"] = "true"
+		for key, value := range data.Aggregate.extra() {
+			sentryEvent.Extra[key] = value
+		}
+	}
+	if data.Container != nil {
+		sentryEvent.Tags["k8s.container"] = data.Container.Name
+		// Correlate issues with deploys through the image tag
+		if tag := imageTag(data.Container.Image); tag != "" {
+			sentryEvent.Release = tag
+		}
+	}
+	if data.Node != nil {
+		sentryEvent.Tags["k8s.node_condition"] = data.Node.Type
+		for key, value := range data.Node.extra() {
+			sentryEvent.Extra[key] = value
+		}
+	}
+	if data.Job != nil {
+		sentryEvent.Tags["k8s.job"] = data.Job.Job
+		if data.Job.CronJob != "" {
+			sentryEvent.Tags["k8s.cronjob"] = data.Job.CronJob
+		}
+		for key, value := range data.Job.extra() {
+			sentryEvent.Extra[key] = value
+		}
+	}
+	if len(data.Overrides) > 0 {
+		sentryEvent.Extra["overrides"] = data.Overrides
 	}
 
 	// Add event timestamps
@@ -169,34 +506,67 @@ func (s *Sender) sendIssue(data EventData, namespace, podName, nodeName, reason,
 		sentryEvent.Extra["k8s_event_count"] = event.Count
 	}
 
+	// Capture on a clone of the destination hub so breadcrumbs stay local to
+	// this event instead of piling up on the shared scope
+	hub := dest.hub.Clone()
+
 	// Add breadcrumbs with kubectl commands for debugging
-	sentry.AddBreadcrumb(&sentry.Breadcrumb{
-		Category: "debug",
-		Message:  fmt.Sprintf("kubectl describe pod %s -n %s", podName, namespace),
-		Level:    sentry.LevelInfo,
-	})
-	sentry.AddBreadcrumb(&sentry.Breadcrumb{
-		Category: "debug",
-		Message:  fmt.Sprintf("kubectl logs %s -n %s --previous", podName, namespace),
-		Level:    sentry.LevelInfo,
-	})
-	sentry.AddBreadcrumb(&sentry.Breadcrumb{
-		Category: "debug",
-		Message:  fmt.Sprintf("kubectl get events -n %s --field-selector involvedObject.name=%s", namespace, podName),
-		Level:    sentry.LevelInfo,
-	})
+	for _, command := range troubleshooting.DebugCommands {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "debug",
+			Message:  command,
+			Level:    sentry.LevelInfo,
+		}, nil)
+	}
+
+	if data.Logs != nil {
+		hub.Scope().AddAttachment(data.Logs.attachment(podName))
+	}
+	if data.Manifest != nil {
+		hub.Scope().AddAttachment(data.Manifest.attachment())
+	}
 
-	sentry.CaptureEvent(sentryEvent)
+	eventID := hub.CaptureEvent(sentryEvent)
+	if eventID == nil {
+		metrics.SendFailures.Inc()
+		return
+	}
+	metrics.IssuesSent.Inc()
+
+	// Aggregated issues span many workloads, none of which is tracked for
+	// recovery; nor are kinds whose recovery can't be checked
+	if data.Aggregate != nil || !recovery.Recoverable(wl.Kind) {
+		return
+	}
+
+	project := dest.name
+	if dest == s.fallback {
+		project = ""
+	}
+	s.tracker.Open(recovery.Issue{
+		Fingerprint: sentryEvent.Fingerprint,
+		Namespace:   namespace,
+		Kind:        wl.Kind,
+		Name:        wl.Name,
+		Reason:      reason,
+		Project:     project,
+		EventID:     string(*eventID),
+		OpenedAt:    time.Now(),
+	})
 }
 
-// Flush waits for all events to be sent.
-func (s *Sender) Flush(timeout time.Duration) bool {
-	return sentry.Flush(timeout)
+// SetTracker records every issue created from now on in tracker, so the
+// watcher can resolve it once the workload recovers.
+func (s *Sender) SetTracker(tracker *recovery.Tracker) {
+	s.tracker = tracker
 }
 
 // DryRunSender prints events to an io.Writer instead of sending to Sentry.
+// Troubleshooting is rendered with the same overrides as Sender's.
 type DryRunSender struct {
 	writer io.Writer
+
+	guidance
 }
 
 // NewDryRunSender creates a sender that outputs to the given writer.
@@ -212,6 +582,7 @@ func (d *DryRunSender) Send(data EventData) {
 	if namespace == "" {
 		namespace = event.Namespace
 	}
+	wl := data.workload()
 
 	output := map[string]interface{}{
 		"message":         fmt.Sprintf("%s: %s", event.Reason, event.InvolvedObject.Name),
@@ -219,12 +590,13 @@ func (d *DryRunSender) Send(data EventData) {
 		"meets_threshold": data.MeetsThreshold,
 		"mode":            getModeString(data.MeetsThreshold),
 		"tags": map[string]string{
-			"k8s.namespace":  namespace,
-			"k8s.pod":        event.InvolvedObject.Name,
-			"k8s.reason":     event.Reason,
-			"k8s.kind":       event.InvolvedObject.Kind,
-			"k8s.node":       event.Source.Host,
-			"k8s.deployment": ExtractDeploymentName(event.InvolvedObject.Name),
+			"k8s.namespace":     namespace,
+			"k8s.pod":           event.InvolvedObject.Name,
+			"k8s.reason":        event.Reason,
+			"k8s.kind":          event.InvolvedObject.Kind,
+			"k8s.node":          event.Source.Host,
+			"k8s.deployment":    wl.Name,
+			"k8s.workload_kind": wl.Kind,
 		},
 		"extra": map[string]interface{}{
 			"message":         event.Message,
@@ -233,7 +605,67 @@ func (d *DryRunSender) Send(data EventData) {
 			"first_seen":      data.FirstSeen.UTC().Format(time.RFC3339),
 			"last_seen":       data.LastSeen.UTC().Format(time.RFC3339),
 		},
-		"fingerprint": []string{"k8s", namespace, ExtractDeploymentName(event.InvolvedObject.Name), event.Reason},
+		"fingerprint": Fingerprint(namespace, wl, event.Reason),
+	}
+	if data.Project != "" {
+		output["project"] = data.Project
+	}
+	troubleshooting := d.troubleshootingFor(event.Reason, namespace).render(templateData(data, namespace, event.Source.Host, wl))
+	output["contexts"] = issueContexts(data, namespace, event.Source.Host, wl, troubleshooting)
+	if data.Container != nil {
+		if tag := imageTag(data.Container.Image); tag != "" {
+			output["release"] = tag
+		}
+	}
+	if data.MeetsThreshold {
+		attachments := map[string]int{}
+		if data.Logs != nil {
+			attachment := data.Logs.attachment(event.InvolvedObject.Name)
+			attachments[attachment.Filename] = len(attachment.Payload)
+		}
+		if data.Manifest != nil {
+			attachment := data.Manifest.attachment()
+			attachments[attachment.Filename] = len(attachment.Payload)
+		}
+		if len(attachments) > 0 {
+			output["attachments"] = attachments
+		}
+	}
+	if data.Node != nil {
+		extra := output["extra"].(map[string]interface{})
+		for key, value := range data.Node.extra() {
+			extra[key] = value
+		}
+	}
+	if data.Job != nil {
+		extra := output["extra"].(map[string]interface{})
+		for key, value := range data.Job.extra() {
+			extra[key] = value
+		}
+	}
+	if data.Flapping {
+		output["tags"].(map[string]string)["k8s.flapping"] = "true"
+		output["extra"].(map[string]interface{})["recurrences"] = data.Recurrences
+	}
+	if data.Aggregate != nil {
+		output["mode"] = "issue (aggregated)"
+		output["tags"].(map[string]string)["k8s.aggregated"] = "true"
+		extra := output["extra"].(map[string]interface{})
+		for key, value := range data.Aggregate.extra() {
+			extra[key] = value
+		}
+	}
+	if data.Reminder {
+		output["mode"] = "log + issue (reminder)"
+		output["tags"].(map[string]string)["k8s.reminder"] = "true"
+		output["extra"].(map[string]interface{})["duration"] = data.LastSeen.Sub(data.FirstSeen).Round(time.Second).String()
+	}
+	if data.Resolved {
+		output["mode"] = "log only (resolved)"
+		output["resolved"] = true
+	}
+	if len(data.Overrides) > 0 {
+		output["overrides"] = data.Overrides
 	}
 
 	jsonData, err := json.MarshalIndent(output, "", "  ")
@@ -256,7 +688,9 @@ func (d *DryRunSender) Flush(_ time.Duration) bool {
 	return true
 }
 
-// TroubleshootingContext provides guidance for debugging k8s events.
+// TroubleshootingContext provides guidance for debugging k8s events. The
+// description, debug commands and runbook URL are text/template templates
+// rendered against the event's TemplateData.
 type TroubleshootingContext struct {
 	Description   string
 	LikelyCauses  []string
@@ -264,6 +698,23 @@ type TroubleshootingContext struct {
 	RunbookURL    string
 }
 
+// merge returns the context with non-empty fields of override applied.
+func (t TroubleshootingContext) merge(override TroubleshootingContext) TroubleshootingContext {
+	if override.Description != "" {
+		t.Description = override.Description
+	}
+	if len(override.LikelyCauses) > 0 {
+		t.LikelyCauses = override.LikelyCauses
+	}
+	if len(override.DebugCommands) > 0 {
+		t.DebugCommands = override.DebugCommands
+	}
+	if override.RunbookURL != "" {
+		t.RunbookURL = override.RunbookURL
+	}
+	return t
+}
+
 func getTroubleshootingContext(reason string) TroubleshootingContext {
 	contexts := map[string]TroubleshootingContext{
 		"OOMKilled": {
@@ -275,12 +726,71 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"Large data processing without streaming",
 			},
 			DebugCommands: []string{
-				"kubectl top pod <pod> -n <namespace>",
-				"kubectl describe pod <pod> -n <namespace> | grep -A5 'Last State'",
-				"kubectl logs <pod> -n <namespace> --previous",
+				"kubectl top pod {{.Pod}} -n {{.Namespace}}",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}} | grep -A5 'Last State'",
+				"kubectl logs {{.Pod}} -n {{.Namespace}} --previous",
 			},
 			RunbookURL: "https://kubernetes.io/docs/tasks/debug/debug-application/debug-running-pod/#container-is-terminated",
 		},
+		"ContainerCrashed": {
+			Description: "Container exited with a non-zero exit code and was restarted.",
+			LikelyCauses: []string{
+				"Unhandled exception or panic in the application",
+				"Exit code 137 without OOMKilled: killed by SIGKILL (e.g. failed liveness probe)",
+				"Exit code 143: terminated by SIGTERM without graceful shutdown",
+				"Missing configuration or unavailable dependency",
+			},
+			DebugCommands: []string{
+				"kubectl logs {{.Pod}} -n {{.Namespace}}{{with .Container}} -c {{.}}{{end}} --previous",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}} | grep -A10 'Last State'",
+			},
+		},
+		"BackoffLimitExceeded": {
+			Description: "Job failed because its pods failed more times than spec.backoffLimit allows.",
+			LikelyCauses: []string{
+				"Application error in the job (see the termination message and logs)",
+				"Missing configuration, secrets or unavailable dependency",
+				"Container OOM killed or evicted on every attempt",
+				"backoffLimit too low for a flaky task",
+			},
+			DebugCommands: []string{
+				"kubectl describe job {{.Job}} -n {{.Namespace}}",
+				"kubectl logs -n {{.Namespace}} -l batch.kubernetes.io/job-name={{.Job}} --previous",
+				"kubectl get pods -n {{.Namespace}} -l batch.kubernetes.io/job-name={{.Job}}",
+			},
+			RunbookURL: "https://kubernetes.io/docs/concepts/workloads/controllers/job/#pod-backoff-failure-policy",
+		},
+		"DeadlineExceeded": {
+			Description: "Job was terminated because it ran longer than spec.activeDeadlineSeconds.",
+			LikelyCauses: []string{
+				"Job is processing more data than usual",
+				"Job is stuck waiting on a dependency or lock",
+				"activeDeadlineSeconds too low for the workload",
+			},
+			DebugCommands: []string{
+				"kubectl describe job {{.Job}} -n {{.Namespace}}",
+				"kubectl logs -n {{.Namespace}} -l batch.kubernetes.io/job-name={{.Job}}",
+			},
+			RunbookURL: "https://kubernetes.io/docs/concepts/workloads/controllers/job/#job-termination-and-cleanup",
+		},
+		"JobFailed": {
+			Description: "Job failed, e.g. because its pod failure policy matched a failure.",
+			DebugCommands: []string{
+				"kubectl describe job {{.Job}} -n {{.Namespace}}",
+				"kubectl get job {{.Job}} -n {{.Namespace}} -o jsonpath='{.status.conditions}'",
+			},
+		},
+		"ContainerRestarted": {
+			Description: "Container exited successfully but was restarted by its restart policy.",
+			LikelyCauses: []string{
+				"Process exits after finishing work but runs under restartPolicy Always",
+				"Entrypoint script exits instead of exec'ing the long-running process",
+			},
+			DebugCommands: []string{
+				"kubectl logs {{.Pod}} -n {{.Namespace}}{{with .Container}} -c {{.}}{{end}} --previous",
+				"kubectl get pod {{.Pod}} -n {{.Namespace}} -o jsonpath='{.spec.restartPolicy}'",
+			},
+		},
 		"CrashLoopBackOff": {
 			Description: "Container keeps crashing and Kubernetes is backing off from restarting it.",
 			LikelyCauses: []string{
@@ -290,9 +800,9 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"Dependency not available (database, external service)",
 			},
 			DebugCommands: []string{
-				"kubectl logs <pod> -n <namespace> --previous",
-				"kubectl describe pod <pod> -n <namespace>",
-				"kubectl get events -n <namespace> --field-selector involvedObject.name=<pod>",
+				"kubectl logs {{.Pod}} -n {{.Namespace}} --previous",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}}",
+				"kubectl get events -n {{.Namespace}} --field-selector involvedObject.name={{.Pod}}",
 			},
 			RunbookURL: "https://kubernetes.io/docs/tasks/debug/debug-application/debug-running-pod/",
 		},
@@ -305,9 +815,9 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"Image name is misspelled",
 			},
 			DebugCommands: []string{
-				"kubectl describe pod <pod> -n <namespace> | grep -A10 Events",
-				"kubectl get secret -n <namespace>",
-				"docker pull <image> (test locally)",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}} | grep -A10 Events",
+				"kubectl get secret -n {{.Namespace}}",
+				`docker pull {{or .Image "<image>"}} (test locally)`,
 			},
 			RunbookURL: "https://kubernetes.io/docs/concepts/containers/images/#image-pull-policy",
 		},
@@ -320,9 +830,9 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"Dependency timeout affecting health check",
 			},
 			DebugCommands: []string{
-				"kubectl describe pod <pod> -n <namespace> | grep -A20 'Liveness\\|Readiness'",
-				"kubectl logs <pod> -n <namespace> --tail=100",
-				"kubectl exec <pod> -n <namespace> -- curl -v localhost:<port>/<health-path>",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}} | grep -A20 'Liveness\\|Readiness'",
+				"kubectl logs {{.Pod}} -n {{.Namespace}} --tail=100",
+				"kubectl exec {{.Pod}} -n {{.Namespace}} -- curl -v localhost:<port>/<health-path>",
 			},
 			RunbookURL: "https://kubernetes.io/docs/tasks/configure-pod-container/configure-liveness-readiness-startup-probes/",
 		},
@@ -335,9 +845,9 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"Pod exceeded ephemeral storage limit",
 			},
 			DebugCommands: []string{
-				"kubectl describe node <node>",
-				"kubectl get pods -A -o wide --field-selector spec.nodeName=<node>",
-				"kubectl top node <node>",
+				"kubectl describe node {{.Node}}",
+				"kubectl get pods -A -o wide --field-selector spec.nodeName={{.Node}}",
+				"kubectl top node {{.Node}}",
 			},
 			RunbookURL: "https://kubernetes.io/docs/concepts/scheduling-eviction/node-pressure-eviction/",
 		},
@@ -350,7 +860,7 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"PersistentVolumeClaim not bound",
 			},
 			DebugCommands: []string{
-				"kubectl describe pod <pod> -n <namespace> | grep -A10 Events",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}} | grep -A10 Events",
 				"kubectl get nodes -o wide",
 				"kubectl describe nodes | grep -A5 'Allocated resources'",
 			},
@@ -365,9 +875,9 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"Volume is already mounted elsewhere (ReadWriteOnce)",
 			},
 			DebugCommands: []string{
-				"kubectl describe pod <pod> -n <namespace>",
-				"kubectl get pv,pvc -n <namespace>",
-				"kubectl get events -n <namespace> | grep -i mount",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}}",
+				"kubectl get pv,pvc -n {{.Namespace}}",
+				"kubectl get events -n {{.Namespace}} | grep -i mount",
 			},
 			RunbookURL: "https://kubernetes.io/docs/concepts/storage/persistent-volumes/",
 		},
@@ -379,8 +889,8 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 				"Repeated failures triggering exponential backoff",
 			},
 			DebugCommands: []string{
-				"kubectl logs <pod> -n <namespace> --previous",
-				"kubectl describe pod <pod> -n <namespace>",
+				"kubectl logs {{.Pod}} -n {{.Namespace}} --previous",
+				"kubectl describe pod {{.Pod}} -n {{.Namespace}}",
 			},
 			RunbookURL: "https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle/#restart-policy",
 		},
@@ -394,13 +904,61 @@ func getTroubleshootingContext(reason string) TroubleshootingContext {
 		Description:  fmt.Sprintf("Kubernetes event: %s", reason),
 		LikelyCauses: []string{"Check pod events and logs for details"},
 		DebugCommands: []string{
-			"kubectl describe pod <pod> -n <namespace>",
-			"kubectl logs <pod> -n <namespace>",
+			"kubectl describe {{.Kind}} {{.Name}}{{with .Namespace}} -n {{.}}{{end}}",
+			"{{with .Pod}}kubectl logs {{.}} -n {{$.Namespace}}{{end}}",
 		},
 		RunbookURL: "https://kubernetes.io/docs/tasks/debug/",
 	}
 }
 
+// Fingerprint returns the Sentry fingerprint grouping events by workload and reason.
+// Deployments keep the fingerprint used before workloads were resolved, so
+// upgrading doesn't regroup their open issues.
+func Fingerprint(namespace string, wl workload.Workload, reason string) []string {
+	if wl.Kind == "Deployment" {
+		return []string{"k8s", namespace, wl.Name, reason}
+	}
+	return []string{"k8s", namespace, wl.Kind, wl.Name, reason}
+}
+
+// GuessWorkload derives a workload from the involved object's name when the
+// ownerReference lookup is unavailable. Names that look like Deployment-managed
+// pods are reported as Deployments and Jobs named like CronJob runs as their
+// CronJob; anything else is its own workload.
+func GuessWorkload(ref corev1.ObjectReference) workload.Workload {
+	if ref.Kind == "Job" {
+		if cronJob, ok := cronJobName(ref.Name); ok {
+			return workload.Workload{Kind: "CronJob", Name: cronJob}
+		}
+	}
+
+	name := ExtractDeploymentName(ref.Name)
+	if ref.Kind == "Pod" && name != ref.Name {
+		return workload.Workload{Kind: "Deployment", Name: name}
+	}
+	return workload.Workload{Kind: ref.Kind, Name: name}
+}
+
+// cronJobName extracts the CronJob name from a Job it created. CronJobs name
+// their Jobs "<cronjob>-<scheduled time in minutes since the epoch>", currently
+// 8 digits, e.g. "backup-29345280" -> "backup".
+func cronJobName(jobName string) (string, bool) {
+	i := strings.LastIndex(jobName, "-")
+	if i <= 0 {
+		return "", false
+	}
+	suffix := jobName[i+1:]
+	if len(suffix) < 8 {
+		return "", false
+	}
+	for _, c := range suffix {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return jobName[:i], true
+}
+
 // ExtractDeploymentName attempts to extract the deployment name from a pod name.
 // Kubernetes pod names typically follow the pattern: deployment-replicaset-pod
 // e.g., "worker-79c6dd4b57-wcdzt" -> "worker"
@@ -1,7 +1,14 @@
 package sentry
 
 import (
+	"bytes"
+	"encoding/json"
+	"slices"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/workload"
 )
 
 func TestExtractDeploymentName(t *testing.T) {
@@ -62,3 +69,256 @@ func TestIsAlphanumeric(t *testing.T) {
 		})
 	}
 }
+
+func TestGuessWorkload(t *testing.T) {
+	tests := []struct {
+		ref  corev1.ObjectReference
+		kind string
+		name string
+	}{
+		{corev1.ObjectReference{Kind: "Pod", Name: "worker-79c6dd4b57-wcdzt"}, "Deployment", "worker"},
+		{corev1.ObjectReference{Kind: "Pod", Name: "redis-0"}, "Pod", "redis-0"},
+		{corev1.ObjectReference{Kind: "Job", Name: "backup-28391"}, "Job", "backup-28391"},
+		{corev1.ObjectReference{Kind: "Job", Name: "nightly-backup-29345280"}, "CronJob", "nightly-backup"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref.Name, func(t *testing.T) {
+			got := GuessWorkload(tt.ref)
+			if got.Kind != tt.kind || got.Name != tt.name {
+				t.Errorf("GuessWorkload(%s/%s) = %s/%s, want %s/%s", tt.ref.Kind, tt.ref.Name, got.Kind, got.Name, tt.kind, tt.name)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		wl       workload.Workload
+		expected []string
+	}{
+		// Same as before workload resolution, so open issues keep grouping
+		{workload.Workload{Kind: "Deployment", Name: "web"}, []string{"k8s", "default", "web", "OOMKilled"}},
+		{workload.Workload{Kind: "StatefulSet", Name: "redis"}, []string{"k8s", "default", "StatefulSet", "redis", "OOMKilled"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.wl.Kind, func(t *testing.T) {
+			if got := Fingerprint("default", tt.wl, "OOMKilled"); !slices.Equal(got, tt.expected) {
+				t.Errorf("Fingerprint(%s/%s) = %v, want %v", tt.wl.Kind, tt.wl.Name, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := []struct {
+		image string
+		tag   string
+	}{
+		{"nginx:1.25", "1.25"},
+		{"registry.example.com:5000/team/api:v1.4.2", "v1.4.2"},
+		{"ghcr.io/acme/worker:2024.10.1@sha256:0123abcd", "2024.10.1"},
+		{"registry.example.com:5000/team/api", ""},
+		{"nginx@sha256:0123abcd", ""},
+		{"nginx:latest", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := imageTag(tt.image); got != tt.tag {
+				t.Errorf("imageTag(%q) = %q, want %q", tt.image, got, tt.tag)
+			}
+		})
+	}
+}
+
+func TestSender_IssueContexts(t *testing.T) {
+	s, transport := newFakeTransportSender(t)
+
+	s.Send(EventData{
+		Event: &corev1.Event{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "api-7d9f8c6b5-abcde"},
+			Reason:         "OOMKilled",
+			Source:         corev1.EventSource{Host: "node-1"},
+		},
+		Severity:       "error",
+		MeetsThreshold: true,
+		Workload: workload.Workload{Kind: "Deployment", Name: "api", Chain: []workload.Object{
+			{Kind: "Pod", Name: "api-7d9f8c6b5-abcde"},
+			{Kind: "ReplicaSet", Name: "api-7d9f8c6b5"},
+			{Kind: "Deployment", Name: "api"},
+		}},
+		Container: &ContainerTermination{
+			Name:         "app",
+			Image:        "registry.example.com/api:v1.4.2",
+			Reason:       "OOMKilled",
+			ExitCode:     137,
+			RestartCount: 3,
+			Resources:    map[string]string{"limits.memory": "256Mi"},
+		},
+		Manifest: &Manifest{
+			Kind:       "Pod",
+			Name:       "api-7d9f8c6b5-abcde",
+			YAML:       []byte("kind: Pod\n"),
+			Scheduling: map[string]interface{}{"node_selector": map[string]string{"disktype": "ssd"}},
+		},
+	})
+
+	events := transport.captured()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 captured event, got %d", len(events))
+	}
+	event := events[0]
+	if event.Release != "v1.4.2" {
+		t.Errorf("expected release from image tag, got %q", event.Release)
+	}
+
+	k8s := event.Contexts["kubernetes"]
+	if k8s["namespace"] != "default" || k8s["pod"] != "api-7d9f8c6b5-abcde" || k8s["node"] != "node-1" || k8s["workload"] != "api" {
+		t.Errorf("unexpected kubernetes context %v", k8s)
+	}
+	if chain, _ := k8s["owner_chain"].([]string); len(chain) != 3 {
+		t.Errorf("expected owner chain in kubernetes context, got %v", k8s["owner_chain"])
+	}
+	if container := event.Contexts["container"]; container["image"] != "registry.example.com/api:v1.4.2" || container["restart_count"] != int32(3) {
+		t.Errorf("unexpected container context %v", container)
+	}
+	if event.Contexts["troubleshooting"]["description"] != getTroubleshootingContext("OOMKilled").Description {
+		t.Errorf("expected troubleshooting context, got %v", event.Contexts["troubleshooting"])
+	}
+	if commands, _ := event.Contexts["troubleshooting"]["debug_commands"].([]string); len(commands) == 0 || commands[0] != "kubectl top pod api-7d9f8c6b5-abcde -n default" {
+		t.Errorf("expected debug commands rendered for the pod, got %v", event.Contexts["troubleshooting"]["debug_commands"])
+	}
+	if event.Contexts["scheduling"]["node_selector"] == nil {
+		t.Errorf("expected scheduling context, got %v", event.Contexts["scheduling"])
+	}
+	if len(event.Attachments) != 1 || event.Attachments[0].Filename != "pod-api-7d9f8c6b5-abcde.yaml" {
+		t.Errorf("expected manifest attachment, got %v", event.Attachments)
+	}
+	if _, ok := event.Extra["description"]; ok {
+		t.Error("expected troubleshooting to be moved out of extra")
+	}
+}
+
+func TestSender_AttachesLogs(t *testing.T) {
+	s, transport := newFakeTransportSender(t)
+
+	logs := &ContainerLogs{Container: "app", Previous: true, Output: []byte("panic: boom\n"), Truncated: true}
+	for _, pod := range []string{"api-7d9f8c6b5-abcde", "web-6b7c8d9e0-klmno"} {
+		data := EventData{
+			Event: &corev1.Event{
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: pod},
+				Reason:         "CrashLoopBackOff",
+			},
+			MeetsThreshold: true,
+		}
+		if pod == "api-7d9f8c6b5-abcde" {
+			data.Logs = logs
+		}
+		s.Send(data)
+	}
+
+	events := transport.captured()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 captured events, got %d", len(events))
+	}
+	if len(events[0].Attachments) != 1 {
+		t.Fatalf("expected 1 attachment, got %d", len(events[0].Attachments))
+	}
+	attachment := events[0].Attachments[0]
+	if attachment.Filename != "api-7d9f8c6b5-abcde-app-previous.log" || string(attachment.Payload) != "[truncated]\npanic: boom\n" {
+		t.Errorf("unexpected attachment %s: %q", attachment.Filename, attachment.Payload)
+	}
+	if string(logs.Output) != "panic: boom\n" {
+		t.Errorf("expected logs to be left unchanged, got %q", logs.Output)
+	}
+	if len(events[1].Attachments) != 0 {
+		t.Errorf("expected attachment not to leak into the next event, got %d", len(events[1].Attachments))
+	}
+}
+
+func TestSender_TroubleshootingOverride(t *testing.T) {
+	s := &Sender{}
+	s.SetTroubleshooting(map[string]TroubleshootingContext{
+		"OOMKilled": {RunbookURL: "https://runbooks.example.com/oom"},
+	})
+
+	got := s.troubleshootingFor("OOMKilled", "default")
+	if got.RunbookURL != "https://runbooks.example.com/oom" {
+		t.Errorf("expected overridden runbook URL, got %s", got.RunbookURL)
+	}
+	if got.Description != getTroubleshootingContext("OOMKilled").Description {
+		t.Error("expected built-in description to be kept")
+	}
+
+	if got := s.troubleshootingFor("CrashLoopBackOff", "default"); got.RunbookURL != getTroubleshootingContext("CrashLoopBackOff").RunbookURL {
+		t.Error("expected reasons without override to use built-in guidance")
+	}
+
+	s.SetRunbooks(map[string]string{"payments": "https://wiki.example.com/payments/{{.Reason}}"})
+	if got := s.troubleshootingFor("OOMKilled", "payments"); got.RunbookURL != "https://wiki.example.com/payments/{{.Reason}}" {
+		t.Errorf("expected namespace runbook to take precedence, got %s", got.RunbookURL)
+	}
+	if got := s.troubleshootingFor("OOMKilled", "default"); got.RunbookURL != "https://runbooks.example.com/oom" {
+		t.Errorf("expected reason runbook outside the namespace, got %s", got.RunbookURL)
+	}
+}
+
+func TestDryRunSender_RendersConfiguredTroubleshooting(t *testing.T) {
+	var buf bytes.Buffer
+	sender := NewDryRunSender(&buf)
+	sender.SetTroubleshooting(map[string]TroubleshootingContext{"OOMKilled": {Description: "Check {{.Pod}}'s heap dump"}})
+	sender.SetRunbooks(map[string]string{"payments": "https://wiki.example.com/{{.Namespace}}/{{.Reason}}"})
+
+	sender.Send(EventData{Event: &corev1.Event{
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "payments", Name: "api-7d9f8c6b5-abcde"},
+		Reason:         "OOMKilled",
+	}})
+
+	var output struct {
+		Contexts struct {
+			Troubleshooting struct {
+				Description string `json:"description"`
+				RunbookURL  string `json:"runbook_url"`
+			} `json:"troubleshooting"`
+		} `json:"contexts"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	troubleshooting := output.Contexts.Troubleshooting
+	if troubleshooting.RunbookURL != "https://wiki.example.com/payments/OOMKilled" {
+		t.Errorf("expected the namespace runbook, got %s", troubleshooting.RunbookURL)
+	}
+	if troubleshooting.Description != "Check api-7d9f8c6b5-abcde's heap dump" {
+		t.Errorf("expected the overridden description, got %s", troubleshooting.Description)
+	}
+}
+
+func TestDryRunSender_ShowsOverrides(t *testing.T) {
+	var buf bytes.Buffer
+	sender := NewDryRunSender(&buf)
+
+	sender.Send(EventData{
+		Event: &corev1.Event{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: "payments", Name: "api-7d9f8c6b5-abcde"},
+			Reason:         "Unhealthy",
+		},
+		Project:   "payments-api",
+		Overrides: map[string]string{"threshold": "10 (Deployment/api)"},
+	})
+
+	var output map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if output["project"] != "payments-api" {
+		t.Errorf("expected project in output, got %v", output["project"])
+	}
+	overrides, _ := output["overrides"].(map[string]interface{})
+	if overrides["threshold"] != "10 (Deployment/api)" {
+		t.Errorf("expected threshold override in output, got %v", output["overrides"])
+	}
+}
@@ -0,0 +1,168 @@
+package sentry
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+// TemplateData is what troubleshooting descriptions, debug commands and
+// runbook URLs are rendered against, e.g. "kubectl logs {{.Pod}} -n {{.Namespace}}".
+// Fields are empty if they don't apply to the event.
+type TemplateData struct {
+	Namespace    string
+	Reason       string
+	Kind         string // Kind of the involved object, e.g. Pod or Job
+	Name         string // Name of the involved object
+	Pod          string
+	Container    string
+	Image        string
+	Node         string
+	Job          string
+	CronJob      string
+	Workload     string
+	WorkloadKind string
+}
+
+// guidance holds the configured troubleshooting overrides. It's shared by the
+// senders, so dry-run output shows what would be sent.
+type guidance struct {
+	mu              sync.RWMutex
+	troubleshooting map[string]TroubleshootingContext // Overrides for the built-in guidance
+	runbooks        map[string]string                 // Runbook URL templates per namespace
+}
+
+// SetTroubleshooting overrides the built-in troubleshooting guidance per reason.
+// Empty fields in an override keep the built-in value.
+func (g *guidance) SetTroubleshooting(overrides map[string]TroubleshootingContext) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.troubleshooting = overrides
+}
+
+// SetRunbooks sets runbook URL templates per namespace, taking precedence
+// over the reason's runbook URL.
+func (g *guidance) SetRunbooks(runbooks map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.runbooks = runbooks
+}
+
+// troubleshootingFor returns the unrendered guidance for a reason in namespace.
+func (g *guidance) troubleshootingFor(reason, namespace string) TroubleshootingContext {
+	g.mu.RLock()
+	override, ok := g.troubleshooting[reason]
+	runbook := g.runbooks[namespace]
+	g.mu.RUnlock()
+
+	ctx := getTroubleshootingContext(reason)
+	if ok {
+		ctx = ctx.merge(override)
+	}
+	if runbook != "" {
+		ctx.RunbookURL = runbook
+	}
+	return ctx
+}
+
+// imagePattern matches the image in kubelet pull messages, e.g.
+// `Back-off pulling image "nginx:1.27"`.
+var imagePattern = regexp.MustCompile(`image "([^"]+)"`)
+
+// templateData returns the values the event's troubleshooting is rendered with.
+func templateData(data EventData, namespace, nodeName string, wl workload.Workload) TemplateData {
+	ref := data.Event.InvolvedObject
+	t := TemplateData{
+		Namespace:    namespace,
+		Reason:       data.Event.Reason,
+		Kind:         ref.Kind,
+		Name:         ref.Name,
+		Container:    ContainerName(data.Event, data.Container),
+		Node:         nodeName,
+		Workload:     wl.Name,
+		WorkloadKind: wl.Kind,
+	}
+
+	switch ref.Kind {
+	case "Pod":
+		t.Pod = ref.Name
+	case "Node":
+		t.Node = ref.Name
+	case "Job":
+		t.Job = ref.Name
+	}
+	if wl.Kind == "CronJob" {
+		t.CronJob = wl.Name
+	}
+	if data.Job != nil {
+		t.Job, t.CronJob = data.Job.Job, data.Job.CronJob
+		if t.Pod == "" {
+			t.Pod = data.Job.Pod
+		}
+		if t.Container == "" {
+			t.Container = data.Job.Container
+		}
+	}
+
+	if data.Container != nil {
+		t.Image = data.Container.Image
+	} else if m := imagePattern.FindStringSubmatch(data.Event.Message); m != nil {
+		t.Image = m[1]
+	}
+	return t
+}
+
+// ContainerName returns the container an event is about, from the synthesised
+// termination or the involved object's field path, e.g. "spec.containers{app}".
+// It's empty if unknown.
+func ContainerName(event *corev1.Event, termination *ContainerTermination) string {
+	if termination != nil {
+		return termination.Name
+	}
+	fieldPath := event.InvolvedObject.FieldPath
+	start, end := strings.Index(fieldPath, "{"), strings.LastIndex(fieldPath, "}")
+	if start < 0 || end <= start {
+		return ""
+	}
+	return fieldPath[start+1 : end]
+}
+
+// render returns the context with its description, debug commands and runbook
+// URL executed as templates against data. Commands that render empty are
+// dropped, so a template can skip a command that doesn't apply to the event.
+func (t TroubleshootingContext) render(data TemplateData) TroubleshootingContext {
+	t.Description = renderTemplate(t.Description, data)
+	t.RunbookURL = renderTemplate(t.RunbookURL, data)
+
+	commands := make([]string, 0, len(t.DebugCommands))
+	for _, command := range t.DebugCommands {
+		if command = renderTemplate(command, data); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	t.DebugCommands = commands
+	return t
+}
+
+// renderTemplate executes text against data, returning it unchanged if it
+// isn't a valid template, e.g. refers to an unknown field.
+func renderTemplate(text string, data TemplateData) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	tmpl, err := template.New("troubleshooting").Parse(text)
+	if err != nil {
+		return text
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return text
+	}
+	return strings.TrimSpace(out.String())
+}
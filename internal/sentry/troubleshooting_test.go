@@ -0,0 +1,148 @@
+package sentry
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+func TestTroubleshootingContext_RendersCatalogue(t *testing.T) {
+	data := TemplateData{
+		Namespace: "payments",
+		Kind:      "Pod",
+		Name:      "api-7d9f8c6b5-abcde",
+		Pod:       "api-7d9f8c6b5-abcde",
+		Container: "app",
+		Node:      "node-1",
+		Job:       "report-28971520",
+	}
+
+	reasons := []string{
+		"OOMKilled", "ContainerCrashed", "BackoffLimitExceeded", "DeadlineExceeded", "JobFailed",
+		"ContainerRestarted", "CrashLoopBackOff", "ImagePullBackOff", "Unhealthy", "Evicted",
+		"FailedScheduling", "FailedMount", "BackOff", "SomethingElse",
+	}
+	for _, reason := range reasons {
+		got := getTroubleshootingContext(reason).render(data)
+		for _, command := range got.DebugCommands {
+			for _, placeholder := range []string{"{{", "<pod>", "<namespace>", "<node>", "<job>", "<container>"} {
+				if strings.Contains(command, placeholder) {
+					t.Errorf("%s: command %q still contains %s", reason, command, placeholder)
+				}
+			}
+		}
+	}
+
+	got := getTroubleshootingContext("ContainerCrashed").render(data)
+	if want := "kubectl logs api-7d9f8c6b5-abcde -n payments -c app --previous"; got.DebugCommands[0] != want {
+		t.Errorf("expected %q, got %q", want, got.DebugCommands[0])
+	}
+}
+
+func TestTroubleshootingContext_RenderSkipsEmptyCommands(t *testing.T) {
+	got := getTroubleshootingContext("NodeNotReady").render(TemplateData{Kind: "Node", Name: "node-1", Node: "node-1"})
+
+	want := []string{"kubectl describe Node node-1"}
+	if !slices.Equal(got.DebugCommands, want) {
+		t.Errorf("expected %v, got %v", want, got.DebugCommands)
+	}
+}
+
+func TestTroubleshootingContext_RenderKeepsInvalidTemplates(t *testing.T) {
+	tc := TroubleshootingContext{
+		DebugCommands: []string{"kubectl get pod {{.Pod", "kubectl get pod {{.Deployment}}"},
+		RunbookURL:    "https://wiki.example.com/{{.Namespace}}/{{.Reason}}",
+	}
+
+	got := tc.render(TemplateData{Namespace: "payments", Reason: "OOMKilled"})
+	if !slices.Equal(got.DebugCommands, tc.DebugCommands) {
+		t.Errorf("expected invalid commands to be kept as written, got %v", got.DebugCommands)
+	}
+	if got.RunbookURL != "https://wiki.example.com/payments/OOMKilled" {
+		t.Errorf("unexpected runbook URL %s", got.RunbookURL)
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     EventData
+		wl       workload.Workload
+		expected TemplateData
+	}{
+		{
+			name: "pod status",
+			data: EventData{
+				Event:     &corev1.Event{Reason: "OOMKilled", InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api-7d9f8c6b5-abcde"}},
+				Container: &ContainerTermination{Name: "app", Image: "registry.example.com/api:v1.4.2"},
+			},
+			wl: workload.Workload{Kind: "Deployment", Name: "api"},
+			expected: TemplateData{
+				Namespace: "default", Reason: "OOMKilled", Kind: "Pod", Name: "api-7d9f8c6b5-abcde",
+				Pod: "api-7d9f8c6b5-abcde", Container: "app", Image: "registry.example.com/api:v1.4.2",
+				Node: "node-1", Workload: "api", WorkloadKind: "Deployment",
+			},
+		},
+		{
+			name: "image pull",
+			data: EventData{Event: &corev1.Event{
+				Reason:         "ImagePullBackOff",
+				Message:        `Back-off pulling image "nginx:1.27"`,
+				InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "web-0", FieldPath: "spec.containers{nginx}"},
+			}},
+			wl: workload.Workload{Kind: "StatefulSet", Name: "web"},
+			expected: TemplateData{
+				Namespace: "default", Reason: "ImagePullBackOff", Kind: "Pod", Name: "web-0",
+				Pod: "web-0", Container: "nginx", Image: "nginx:1.27",
+				Node: "node-1", Workload: "web", WorkloadKind: "StatefulSet",
+			},
+		},
+		{
+			name: "failed job",
+			data: EventData{
+				Event: &corev1.Event{Reason: "BackoffLimitExceeded", InvolvedObject: corev1.ObjectReference{Kind: "Job", Name: "report-28971520"}},
+				Job:   &JobFailure{Job: "report-28971520", CronJob: "report", Pod: "report-28971520-x7k2p", Container: "main"},
+			},
+			wl: workload.Workload{Kind: "CronJob", Name: "report"},
+			expected: TemplateData{
+				Namespace: "default", Reason: "BackoffLimitExceeded", Kind: "Job", Name: "report-28971520",
+				Pod: "report-28971520-x7k2p", Container: "main", Node: "node-1",
+				Job: "report-28971520", CronJob: "report", Workload: "report", WorkloadKind: "CronJob",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := templateData(tt.data, "default", "node-1", tt.wl); got != tt.expected {
+				t.Errorf("templateData() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainerName(t *testing.T) {
+	tests := []struct {
+		fieldPath   string
+		termination *ContainerTermination
+		expected    string
+	}{
+		{"spec.containers{app}", nil, "app"},
+		{"spec.initContainers{migrate}", nil, "migrate"},
+		{"", nil, ""},
+		{"", &ContainerTermination{Name: "sidecar"}, "sidecar"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fieldPath+tt.expected, func(t *testing.T) {
+			event := &corev1.Event{InvolvedObject: corev1.ObjectReference{Kind: "Pod", FieldPath: tt.fieldPath}}
+			if got := ContainerName(event, tt.termination); got != tt.expected {
+				t.Errorf("ContainerName(%q) = %q, want %q", tt.fieldPath, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package state
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ConfigMapStore keeps state under one key of a ConfigMap, so it survives pod
+// restarts and is shared with the replica that takes over leadership.
+// Several stores can share a ConfigMap using different keys.
+type ConfigMapStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	key       string
+}
+
+// NewConfigMapStore creates a store for key in the ConfigMap namespace/name.
+// The ConfigMap is created on the first save.
+func NewConfigMapStore(client kubernetes.Interface, namespace, name, key string) *ConfigMapStore {
+	return &ConfigMapStore{client: client, namespace: namespace, name: name, key: key}
+}
+
+// Load returns the value of the key, or nil if the ConfigMap or key doesn't exist.
+func (s *ConfigMapStore) Load(ctx context.Context) ([]byte, error) {
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state ConfigMap %s/%s: %w", s.namespace, s.name, err)
+	}
+	value, ok := cm.Data[s.key]
+	if !ok {
+		return nil, nil
+	}
+	return []byte(value), nil
+}
+
+// Save writes the value of the key, creating the ConfigMap if needed.
+func (s *ConfigMapStore) Save(ctx context.Context, data []byte) error {
+	configMaps := s.client.CoreV1().ConfigMaps(s.namespace)
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: s.namespace,
+					Name:      s.name,
+					Labels:    map[string]string{"app.kubernetes.io/managed-by": "kube-sentry-events"},
+				},
+				Data: map[string]string{s.key: string(data)},
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created concurrently; retry as an update
+				return apierrors.NewConflict(corev1.Resource("configmaps"), s.name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[s.key] = string(data)
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save state ConfigMap %s/%s: %w", s.namespace, s.name, err)
+	}
+	return nil
+}
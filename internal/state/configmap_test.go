@@ -0,0 +1,67 @@
+package state
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapStore_LoadMissing(t *testing.T) {
+	store := NewConfigMapStore(fake.NewClientset(), "monitoring", "kube-sentry-events-state", "issues.json")
+
+	data, err := store.Load(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data != nil {
+		t.Errorf("expected nil for missing ConfigMap, got %q", data)
+	}
+}
+
+func TestConfigMapStore_SaveAndLoad(t *testing.T) {
+	client := fake.NewClientset()
+	ctx := context.Background()
+	issues := NewConfigMapStore(client, "monitoring", "kube-sentry-events-state", "issues.json")
+	other := NewConfigMapStore(client, "monitoring", "kube-sentry-events-state", "other.json")
+
+	if err := issues.Save(ctx, []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("unexpected error creating: %v", err)
+	}
+	if err := other.Save(ctx, []byte(`{"b":2}`)); err != nil {
+		t.Fatalf("unexpected error updating: %v", err)
+	}
+	if err := issues.Save(ctx, []byte(`{"a":3}`)); err != nil {
+		t.Fatalf("unexpected error updating: %v", err)
+	}
+
+	data, err := issues.Load(ctx)
+	if err != nil || string(data) != `{"a":3}` {
+		t.Errorf("expected latest value, got %q (%v)", data, err)
+	}
+	data, err = other.Load(ctx)
+	if err != nil || string(data) != `{"b":2}` {
+		t.Errorf("expected keys to be kept separately, got %q (%v)", data, err)
+	}
+}
+
+func TestConfigMapStore_KeepsUnrelatedData(t *testing.T) {
+	client := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: "state"},
+		Data:       map[string]string{"unrelated": "keep"},
+	})
+	store := NewConfigMapStore(client, "monitoring", "state", "issues.json")
+
+	if err := store.Save(context.Background(), []byte("[]")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cm, err := client.CoreV1().ConfigMaps("monitoring").Get(context.Background(), "state", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["unrelated"] != "keep" || cm.Data["issues.json"] != "[]" {
+		t.Errorf("unexpected data %v", cm.Data)
+	}
+}
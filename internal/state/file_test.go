@@ -0,0 +1,38 @@
+package state
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dedup.json")
+	store := NewFileStore(path)
+	ctx := context.Background()
+
+	data, err := store.Load(ctx)
+	if err != nil || data != nil {
+		t.Fatalf("expected nil for missing file, got %q (%v)", data, err)
+	}
+
+	for _, value := range []string{`{"a":1}`, `{"a":2}`} {
+		if err := store.Save(ctx, []byte(value)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	data, err = store.Load(ctx)
+	if err != nil || string(data) != `{"a":2}` {
+		t.Errorf("expected latest value, got %q (%v)", data, err)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be cleaned up, got %d entries", len(entries))
+	}
+}
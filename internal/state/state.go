@@ -0,0 +1,12 @@
+// Package state persists small pieces of runtime state across restarts and
+// leader changes.
+package state
+
+import "context"
+
+// Store loads and saves a blob of state.
+type Store interface {
+	// Load returns the saved state, or nil if nothing has been saved yet.
+	Load(ctx context.Context) ([]byte, error)
+	Save(ctx context.Context, data []byte) error
+}
@@ -0,0 +1,184 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+// Reasons of events synthesised from failed Jobs. BackoffLimitExceeded and
+// DeadlineExceeded match the Failed condition reasons (and the job controller's
+// Events) so both deduplicate together; other failure reasons, such as
+// PodFailurePolicy, are reported as JobFailed.
+const (
+	ReasonBackoffLimitExceeded = "BackoffLimitExceeded"
+	ReasonDeadlineExceeded     = "DeadlineExceeded"
+	ReasonJobFailed            = "JobFailed"
+)
+
+// jobFailureReasons are the event reasons that describe a failed Job.
+var jobFailureReasons = map[string]bool{
+	ReasonBackoffLimitExceeded: true,
+	ReasonDeadlineExceeded:     true,
+	ReasonJobFailed:            true,
+}
+
+// handleJobUpdate synthesises an event when a Job's Failed condition becomes
+// True and runs it through the usual pipeline.
+func (w *Watcher) handleJobUpdate(ctx context.Context, oldObj, newObj interface{}) {
+	oldJob, ok := oldObj.(*batchv1.Job)
+	if !ok {
+		return
+	}
+	newJob, ok := newObj.(*batchv1.Job)
+	if !ok {
+		return
+	}
+
+	condition := failedCondition(newJob)
+	if condition == nil || failedCondition(oldJob) != nil {
+		return
+	}
+
+	// The failing pod is only looked up once the event is known to make an issue
+	event := jobEvent(newJob, condition)
+	w.process(ctx, event, sentry.EventData{Job: newJobFailure(newJob, condition)})
+}
+
+// failedCondition returns the Job's Failed condition if it is True.
+func failedCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		c := &job.Status.Conditions[i]
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return c
+		}
+	}
+	return nil
+}
+
+// jobEvent builds a core Event for a failed Job.
+func jobEvent(job *batchv1.Job, condition *batchv1.JobCondition) *corev1.Event {
+	reason := condition.Reason
+	if !jobFailureReasons[reason] {
+		reason = ReasonJobFailed
+	}
+
+	message := "Job has failed"
+	if condition.Message != "" {
+		message = condition.Message
+	}
+
+	transitioned := condition.LastTransitionTime
+	if transitioned.IsZero() {
+		transitioned = metav1.Now()
+	}
+
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: job.Namespace,
+			Name:      fmt.Sprintf("%s.failed.%d", job.Name, transitioned.Unix()),
+			UID:       types.UID(fmt.Sprintf("%s/failed", job.UID)),
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:       "Job",
+			APIVersion: "batch/v1",
+			Namespace:  job.Namespace,
+			Name:       job.Name,
+			UID:        job.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Count:          1,
+		Source:         corev1.EventSource{Component: "kube-sentry-events"},
+		FirstTimestamp: transitioned,
+		LastTimestamp:  transitioned,
+	}
+}
+
+// newJobFailure describes a failed Job from its Failed condition.
+func newJobFailure(job *batchv1.Job, condition *batchv1.JobCondition) *sentry.JobFailure {
+	return &sentry.JobFailure{
+		Job:     job.Name,
+		CronJob: cronJobOf(job),
+		Reason:  condition.Reason,
+		Message: condition.Message,
+	}
+}
+
+// jobFailure describes a failed Job, including the termination of its most
+// recently failed container if one is still around. Finding it lists the
+// Job's pods, so it's only done for events that make an issue.
+func (w *Watcher) jobFailure(ctx context.Context, job *batchv1.Job, condition *batchv1.JobCondition) *sentry.JobFailure {
+	failure := newJobFailure(job, condition)
+
+	// The Job's selector matches its pods on every version; fall back to the name label
+	selector := batchv1.JobNameLabel + "=" + job.Name
+	if job.Spec.Selector != nil {
+		if s, err := metav1.LabelSelectorAsSelector(job.Spec.Selector); err == nil {
+			selector = s.String()
+		}
+	}
+
+	pods, err := w.client.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		w.logger.Debug("failed to list job pods", "namespace", job.Namespace, "job", job.Name, "error", err)
+		return failure
+	}
+
+	var latest *corev1.ContainerStateTerminated
+	for _, pod := range pods.Items {
+		for _, cs := range allContainerStatuses(&pod) {
+			for _, terminated := range []*corev1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+				if terminated == nil || terminated.ExitCode == 0 {
+					continue
+				}
+				if latest != nil && !terminated.FinishedAt.After(latest.FinishedAt.Time) {
+					continue
+				}
+				latest = terminated
+				failure.Pod = pod.Name
+				failure.Container = cs.Name
+				failure.ExitCode = terminated.ExitCode
+				failure.TerminationMessage = terminated.Message
+			}
+		}
+	}
+	return failure
+}
+
+// lookupJobFailure fetches a failed Job, from the Job informer's cache if it
+// runs, and describes its failure. It returns nil if the Job is gone or
+// hasn't failed.
+func (w *Watcher) lookupJobFailure(ctx context.Context, namespace, name string) *sentry.JobFailure {
+	var job *batchv1.Job
+	if w.jobs != nil {
+		job, _ = w.jobs.Jobs(namespace).Get(name)
+	}
+	if job == nil {
+		var err error
+		if job, err = w.client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+			w.logger.Debug("failed to get job", "namespace", namespace, "job", name, "error", err)
+			return nil
+		}
+	}
+	condition := failedCondition(job)
+	if condition == nil {
+		return nil
+	}
+	return w.jobFailure(ctx, job, condition)
+}
+
+// cronJobOf returns the name of the CronJob controlling the Job, or "".
+func cronJobOf(job *batchv1.Job) string {
+	if owner := metav1.GetControllerOf(job); owner != nil && owner.Kind == "CronJob" {
+		return owner.Name
+	}
+	return ""
+}
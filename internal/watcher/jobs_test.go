@@ -0,0 +1,169 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/imankulov/kube-sentry-events/internal/filter"
+)
+
+func newCronJobRun(name string, failed bool) *batchv1.Job {
+	isController := true
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			UID:       types.UID("job-uid-" + name),
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "batch/v1", Kind: "CronJob", Name: "backup", Controller: &isController},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "uid-" + name}},
+		},
+	}
+	if failed {
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type:    batchv1.JobFailed,
+			Status:  corev1.ConditionTrue,
+			Reason:  ReasonBackoffLimitExceeded,
+			Message: "Job has reached the specified backoff limit",
+		}}
+	}
+	return job
+}
+
+func newFailedJobPod(jobName, message string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      jobName + "-x7k2p",
+			Labels:    map[string]string{"controller-uid": "uid-" + jobName},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name: "backup",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				ExitCode:   2,
+				Message:    message,
+				FinishedAt: metav1.Now(),
+			}},
+		}}},
+	}
+}
+
+func newJobFilter() *filter.Filter {
+	return filter.New(nil, nil, []string{ReasonBackoffLimitExceeded, ReasonDeadlineExceeded, ReasonJobFailed}, nil)
+}
+
+func TestJobEvent_Reasons(t *testing.T) {
+	tests := []struct {
+		conditionReason string
+		want            string
+	}{
+		{ReasonBackoffLimitExceeded, ReasonBackoffLimitExceeded},
+		{ReasonDeadlineExceeded, ReasonDeadlineExceeded},
+		{"PodFailurePolicy", ReasonJobFailed},
+		{"", ReasonJobFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.conditionReason, func(t *testing.T) {
+			job := newCronJobRun("backup-29345280", false)
+			event := jobEvent(job, &batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: tt.conditionReason})
+			if event.Reason != tt.want {
+				t.Errorf("expected reason %s, got %s", tt.want, event.Reason)
+			}
+			if event.InvolvedObject.Kind != "Job" || event.InvolvedObject.Name != job.Name {
+				t.Errorf("expected event for the Job, got %+v", event.InvolvedObject)
+			}
+		})
+	}
+}
+
+func TestWatcher_JobFailureAttributedToCronJob(t *testing.T) {
+	cronJob := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup"}}
+	first := newCronJobRun("backup-29345280", true)
+	second := newCronJobRun("backup-29345340", true)
+	w, sender := newTestWatcher(cronJob, first, second,
+		newFailedJobPod(first.Name, "pg_dump: connection refused"),
+		newFailedJobPod(second.Name, "pg_dump: connection refused"),
+	)
+	w.SetFilter(newJobFilter())
+	ctx := context.Background()
+
+	// Conditions that were already failed aren't reported again
+	w.handleJobUpdate(ctx, first, first)
+	if len(sender.sent) != 0 {
+		t.Fatalf("expected no event for an unchanged failed Job, got %d", len(sender.sent))
+	}
+
+	w.handleJobUpdate(ctx, newCronJobRun(first.Name, false), first)
+	if sender.issues() != 1 {
+		t.Fatalf("expected failed Job to create an issue, got %d", sender.issues())
+	}
+	data := sender.sent[0]
+	if data.Workload.Kind != "CronJob" || data.Workload.Name != "backup" {
+		t.Errorf("expected issue attributed to CronJob/backup, got %s/%s", data.Workload.Kind, data.Workload.Name)
+	}
+	if data.Job == nil || data.Job.CronJob != "backup" || data.Job.Job != first.Name {
+		t.Fatalf("expected job details, got %+v", data.Job)
+	}
+	if data.Job.TerminationMessage != "pg_dump: connection refused" || data.Job.ExitCode != 2 || data.Job.Container != "backup" {
+		t.Errorf("expected failing container's termination, got %+v", data.Job)
+	}
+
+	// The next run of the same CronJob groups with the first
+	w.handleJobUpdate(ctx, newCronJobRun(second.Name, false), second)
+	if sender.issues() != 1 {
+		t.Errorf("expected runs of one CronJob to deduplicate, got %d issues", sender.issues())
+	}
+}
+
+func TestWatcher_FilteredJobFailureDoesNotListPods(t *testing.T) {
+	job := newCronJobRun("backup-29345280", true)
+	w, sender := newTestWatcher(job, newFailedJobPod(job.Name, "disk full"))
+	w.SetFilter(filter.New([]string{"payments"}, nil, []string{ReasonBackoffLimitExceeded}, nil))
+
+	w.handleJobUpdate(context.Background(), newCronJobRun(job.Name, false), job)
+
+	if len(sender.sent) != 0 {
+		t.Fatalf("expected Job outside the watched namespaces to be filtered, got %d sends", len(sender.sent))
+	}
+	for _, action := range w.client.(*fake.Clientset).Actions() {
+		if action.Matches("list", "pods") {
+			t.Error("expected no pods to be listed for a filtered Job")
+		}
+	}
+}
+
+func TestWatcher_JobControllerEventGetsFailingPod(t *testing.T) {
+	job := newCronJobRun("backup-29345280", true)
+	w, sender := newTestWatcher(job, newFailedJobPod(job.Name, "disk full"))
+	w.SetFilter(newJobFilter())
+
+	w.processEvent(context.Background(), &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-29345280.1", UID: "event-uid"},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Job",
+			Namespace: "default",
+			Name:      job.Name,
+		},
+		Reason:  ReasonBackoffLimitExceeded,
+		Message: "Job has reached the specified backoff limit",
+		Type:    corev1.EventTypeWarning,
+		Count:   1,
+	})
+
+	if sender.issues() != 1 {
+		t.Fatalf("expected an issue, got %d", sender.issues())
+	}
+	if got := sender.sent[0].Job; got == nil || got.TerminationMessage != "disk full" {
+		t.Errorf("expected the failing pod's termination message, got %+v", got)
+	}
+}
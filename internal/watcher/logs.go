@@ -0,0 +1,145 @@
+package watcher
+
+import (
+	"context"
+	"io"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+// PreviousLogs configures attaching a crashed container's logs to issues.
+// Logs may contain secrets, so they're only fetched for the listed reasons in
+// the listed namespaces.
+type PreviousLogs struct {
+	Reasons    []string
+	Namespaces []string
+	TailLines  int64         // Last lines fetched
+	MaxBytes   int64         // Only the last MaxBytes of the logs are kept
+	Timeout    time.Duration // Logs are skipped if fetching takes longer
+}
+
+// logSettings is PreviousLogs with the reasons and namespaces as sets.
+type logSettings struct {
+	PreviousLogs
+	reasons    map[string]struct{}
+	namespaces map[string]struct{}
+}
+
+// SetPreviousLogs enables attaching the previous container's logs to issues,
+// fetched through the pods/log subresource. Empty reasons or namespaces
+// disable it. It's safe to call while the watcher runs, e.g. on config reload.
+func (w *Watcher) SetPreviousLogs(cfg PreviousLogs) {
+	if len(cfg.Reasons) == 0 || len(cfg.Namespaces) == 0 {
+		w.logs.Store(nil)
+		return
+	}
+	s := &logSettings{
+		PreviousLogs: cfg,
+		reasons:      make(map[string]struct{}, len(cfg.Reasons)),
+		namespaces:   make(map[string]struct{}, len(cfg.Namespaces)),
+	}
+	for _, reason := range cfg.Reasons {
+		s.reasons[reason] = struct{}{}
+	}
+	for _, ns := range cfg.Namespaces {
+		s.namespaces[ns] = struct{}{}
+	}
+	w.logs.Store(s)
+}
+
+// covers returns true if logs should be attached to an issue for the event.
+func (s *logSettings) covers(namespace string, event *corev1.Event) bool {
+	if s == nil || event.InvolvedObject.Kind != "Pod" {
+		return false
+	}
+	if _, ok := s.reasons[event.Reason]; !ok {
+		return false
+	}
+	_, ok := s.namespaces[namespace]
+	return ok
+}
+
+// fetchLogs returns the last lines of the container's previous instance, or
+// of the current one if it hasn't restarted, within the configured budgets.
+// It returns nil if neither can be read, e.g. the pod is gone or RBAC forbids it.
+func (w *Watcher) fetchLogs(ctx context.Context, s *logSettings, namespace, pod, container string) *sentry.ContainerLogs {
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	for _, previous := range []bool{true, false} {
+		output, truncated, err := w.readLogs(ctx, s, namespace, pod, container, previous)
+		if err != nil {
+			w.logger.Debug("failed to fetch container logs",
+				"namespace", namespace,
+				"pod", pod,
+				"container", container,
+				"previous", previous,
+				"error", err,
+			)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		metrics.PreviousLogs.WithLabelValues("attached").Inc()
+		return &sentry.ContainerLogs{
+			Container: container,
+			Previous:  previous,
+			Output:    output,
+			Truncated: truncated,
+		}
+	}
+	metrics.PreviousLogs.WithLabelValues("failed").Inc()
+	return nil
+}
+
+// readLogs streams the container's last lines, keeping at most their last
+// MaxBytes: the lines right before the exit matter most.
+func (w *Watcher) readLogs(ctx context.Context, s *logSettings, namespace, pod, container string, previous bool) ([]byte, bool, error) {
+	tailLines := s.TailLines
+	stream, err := w.client.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+		TailLines: &tailLines,
+	}).Stream(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer stream.Close()
+
+	tail := &tailBuffer{max: int(s.MaxBytes)}
+	if _, err := io.Copy(tail, stream); err != nil {
+		return nil, false, err
+	}
+	output, truncated := tail.bytes()
+	return output, truncated, nil
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	// Drop the head once twice the budget is held, so copying stays amortised
+	if len(b.buf) > 2*b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// bytes returns the kept bytes and whether earlier ones were dropped.
+func (b *tailBuffer) bytes() ([]byte, bool) {
+	if len(b.buf) > b.max {
+		return b.buf[len(b.buf)-b.max:], true
+	}
+	return b.buf, b.truncated
+}
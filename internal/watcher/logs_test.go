@@ -0,0 +1,96 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imankulov/kube-sentry-events/internal/filter"
+)
+
+func TestWatcher_AttachesPreviousLogs(t *testing.T) {
+	w, sender := newTestWatcher()
+	w.SetFilter(filter.New(nil, nil, []string{ReasonOOMKilled}, nil))
+	w.SetPreviousLogs(PreviousLogs{
+		Reasons:    []string{ReasonOOMKilled},
+		Namespaces: []string{"default"},
+		TailLines:  100,
+		MaxBytes:   4,
+		Timeout:    time.Second,
+	})
+
+	w.handlePodUpdate(context.Background(), newStatusPod(restartedStatus(0, "", 0)), newStatusPod(restartedStatus(1, "OOMKilled", 137)))
+
+	if sender.issues() != 1 {
+		t.Fatalf("expected OOM kill to create an issue, got %d", sender.issues())
+	}
+	logs := sender.sent[0].Logs
+	if logs == nil {
+		t.Fatal("expected container logs to be attached")
+	}
+	// The fake clientset always returns "fake logs"; the end is kept
+	if logs.Container != "app" || !logs.Previous || string(logs.Output) != "logs" || !logs.Truncated {
+		t.Errorf("unexpected logs %+v (%q)", logs, logs.Output)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	tail := &tailBuffer{max: 16}
+	for i := 1; i <= 100; i++ {
+		fmt.Fprintf(tail, "line %d\n", i)
+	}
+
+	output, truncated := tail.bytes()
+	if !truncated || len(output) != 16 {
+		t.Fatalf("expected 16 truncated bytes, got %q (%v)", output, truncated)
+	}
+	if !strings.HasSuffix(string(output), "\nline 100\n") {
+		t.Errorf("expected the final line to be kept, got %q", output)
+	}
+
+	short := &tailBuffer{max: 16}
+	fmt.Fprint(short, "panic: boom\n")
+	if output, truncated := short.bytes(); truncated || string(output) != "panic: boom\n" {
+		t.Errorf("expected short logs to be kept whole, got %q (%v)", output, truncated)
+	}
+}
+
+func TestWatcher_PreviousLogsRespectAllowlist(t *testing.T) {
+	w, sender := newTestWatcher()
+	w.SetFilter(filter.New(nil, nil, []string{ReasonOOMKilled, "CrashLoopBackOff"}, map[string]int32{"CrashLoopBackOff": 1}))
+	w.SetPreviousLogs(PreviousLogs{
+		Reasons:    []string{"CrashLoopBackOff"},
+		Namespaces: []string{"payments"},
+		TailLines:  100,
+		MaxBytes:   1024,
+		Timeout:    time.Second,
+	})
+	ctx := context.Background()
+
+	// Reason not opted in
+	pod := newStatusPod(restartedStatus(0, "", 0))
+	pod.Namespace = "payments"
+	crashed := newStatusPod(restartedStatus(1, "OOMKilled", 137))
+	crashed.Namespace = "payments"
+	w.handlePodUpdate(ctx, pod, crashed)
+
+	// Namespace not allowed
+	w.processEvent(ctx, newWatchedEvent("1", "1", time.Now()))
+
+	// Both allowed
+	event := newWatchedEvent("2", "2", time.Now())
+	event.Namespace, event.InvolvedObject.Namespace = "payments", "payments"
+	w.processEvent(ctx, event)
+
+	if sender.issues() != 3 {
+		t.Fatalf("expected 3 issues, got %d", sender.issues())
+	}
+	for i, want := range []bool{false, false, true} {
+		if got := sender.sent[i].Logs != nil; got != want {
+			t.Errorf("event %d (%s/%s): expected logs attached %v, got %v", i,
+				sender.sent[i].Event.InvolvedObject.Namespace, sender.sent[i].Event.Reason, want, got)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/imankulov/kube-sentry-events/internal/manifest"
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+// SetManifestReasons attaches a sanitised snapshot of the involved object to
+// issues for the given reasons, e.g. the pod spec for FailedMount. It's safe
+// to call while the watcher runs, e.g. on config reload.
+func (w *Watcher) SetManifestReasons(reasons []string) {
+	set := make(map[string]struct{}, len(reasons))
+	for _, reason := range reasons {
+		set[reason] = struct{}{}
+	}
+	w.manifestReasons.Store(&set)
+}
+
+// wantsManifest returns true if issues for the reason get a manifest attached.
+func (w *Watcher) wantsManifest(reason string) bool {
+	reasons := w.manifestReasons.Load()
+	if reasons == nil {
+		return false
+	}
+	_, ok := (*reasons)[reason]
+	return ok
+}
+
+// fetchManifest returns the snapshot of the involved object, or nil if it
+// can't be fetched, e.g. it's gone, of an unsupported kind or RBAC forbids it.
+func (w *Watcher) fetchManifest(ctx context.Context, namespace string, ref corev1.ObjectReference) *sentry.Manifest {
+	if !manifest.IsSupportedKind(ref.Kind) {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, manifestTimeout)
+	defer cancel()
+	snapshot, err := w.manifest.Fetch(ctx, namespace, ref)
+	if err != nil {
+		result := "failed"
+		if apierrors.IsForbidden(err) {
+			result = "forbidden"
+		}
+		metrics.Manifests.WithLabelValues(result).Inc()
+		w.logger.Debug("failed to fetch manifest",
+			"namespace", namespace,
+			"kind", ref.Kind,
+			"name", ref.Name,
+			"error", err,
+		)
+		return nil
+	}
+	metrics.Manifests.WithLabelValues("attached").Inc()
+	return &sentry.Manifest{
+		Kind:       snapshot.Kind,
+		Name:       snapshot.Name,
+		YAML:       snapshot.YAML,
+		Scheduling: snapshot.Scheduling,
+	}
+}
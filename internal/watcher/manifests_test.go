@@ -0,0 +1,56 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/filter"
+)
+
+func TestWatcher_AttachesManifest(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "worker-79c6dd4b57-wcdzt"},
+		Spec:       corev1.PodSpec{NodeSelector: map[string]string{"disktype": "ssd"}},
+	}
+	w, sender := newTestWatcher(pod)
+	w.SetFilter(filter.New(nil, nil, []string{"FailedMount", "CrashLoopBackOff"}, map[string]int32{"FailedMount": 1, "CrashLoopBackOff": 1}))
+	w.SetManifestReasons([]string{"FailedMount"})
+	ctx := context.Background()
+
+	mount := newWatchedEvent("1", "1", time.Now())
+	mount.Reason = "FailedMount"
+	w.processEvent(ctx, mount)
+	w.processEvent(ctx, newWatchedEvent("2", "2", time.Now()))
+
+	if sender.issues() != 2 {
+		t.Fatalf("expected 2 issues, got %d", sender.issues())
+	}
+	m := sender.sent[0].Manifest
+	if m == nil || m.Kind != "Pod" || m.Name != pod.Name || len(m.YAML) == 0 {
+		t.Fatalf("expected pod manifest for FailedMount, got %+v", m)
+	}
+	if m.Scheduling["node_selector"] == nil {
+		t.Errorf("expected scheduling summary, got %v", m.Scheduling)
+	}
+	if sender.sent[1].Manifest != nil {
+		t.Error("expected no manifest for CrashLoopBackOff")
+	}
+}
+
+func TestWatcher_ManifestSkippedForMissingObject(t *testing.T) {
+	w, sender := newTestWatcher()
+	w.SetManifestReasons([]string{"CrashLoopBackOff"})
+
+	w.processEvent(context.Background(), newWatchedEvent("1", "1", time.Now()))
+
+	if sender.issues() != 1 {
+		t.Fatalf("expected the issue to be sent without a manifest, got %d issues", sender.issues())
+	}
+	if sender.sent[0].Manifest != nil {
+		t.Errorf("expected no manifest, got %+v", sender.sent[0].Manifest)
+	}
+}
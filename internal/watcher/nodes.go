@@ -0,0 +1,195 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/imankulov/kube-sentry-events/internal/recovery"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+// Reasons of events synthesised from Node conditions.
+const (
+	ReasonNodeNotReady       = "NodeNotReady"
+	ReasonNodeMemoryPressure = "NodeMemoryPressure"
+	ReasonNodeDiskPressure   = "NodeDiskPressure"
+	ReasonNodePIDPressure    = "NodePIDPressure"
+)
+
+// maxNodePods bounds the number of affected pods attached to a node issue.
+const maxNodePods = 50
+
+// nodeConditionReasons maps monitored condition types to event reasons.
+var nodeConditionReasons = map[corev1.NodeConditionType]string{
+	corev1.NodeReady:          ReasonNodeNotReady,
+	corev1.NodeMemoryPressure: ReasonNodeMemoryPressure,
+	corev1.NodeDiskPressure:   ReasonNodeDiskPressure,
+	corev1.NodePIDPressure:    ReasonNodePIDPressure,
+}
+
+// conditionChange is a monitored node condition entering or leaving a bad state.
+type conditionChange struct {
+	condition corev1.NodeCondition
+	reason    string
+	resolved  bool
+}
+
+// handleNodeUpdate raises an issue for each condition that turned bad and a
+// resolution log for each that cleared.
+func (w *Watcher) handleNodeUpdate(ctx context.Context, oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*corev1.Node)
+	if !ok {
+		return
+	}
+
+	for _, change := range nodeConditionChanges(oldNode, newNode) {
+		event := nodeEvent(newNode, change)
+		details := &sentry.NodeCondition{
+			Type:        string(change.condition.Type),
+			Status:      string(change.condition.Status),
+			Reason:      change.condition.Reason,
+			Message:     change.condition.Message,
+			Capacity:    resourceStrings(newNode.Status.Capacity),
+			Allocatable: resourceStrings(newNode.Status.Allocatable),
+		}
+
+		if change.resolved {
+			w.sendResolved(ctx, event, details)
+			continue
+		}
+		details.Pods = w.podsOnNode(ctx, newNode.Name)
+		w.process(ctx, event, sentry.EventData{Node: details})
+	}
+}
+
+// nodeConditionChanges compares the monitored conditions of two Node versions.
+// Conditions missing from the old version are treated as healthy.
+func nodeConditionChanges(oldNode, newNode *corev1.Node) []conditionChange {
+	oldBad := make(map[corev1.NodeConditionType]bool)
+	for _, c := range oldNode.Status.Conditions {
+		oldBad[c.Type] = isBadCondition(c)
+	}
+
+	var changes []conditionChange
+	for _, c := range newNode.Status.Conditions {
+		reason := nodeConditionReasons[c.Type]
+		if reason == "" {
+			continue
+		}
+		wasBad, bad := oldBad[c.Type], isBadCondition(c)
+		if wasBad != bad {
+			changes = append(changes, conditionChange{condition: c, reason: reason, resolved: !bad})
+		}
+	}
+	return changes
+}
+
+// isBadCondition returns true if Ready isn't True, or a pressure condition is True.
+func isBadCondition(c corev1.NodeCondition) bool {
+	if c.Type == corev1.NodeReady {
+		return c.Status != corev1.ConditionTrue
+	}
+	return c.Status == corev1.ConditionTrue
+}
+
+// nodeEvent builds a core Event for a node condition change. Nodes are
+// cluster-scoped, so the event has no namespace.
+func nodeEvent(node *corev1.Node, change conditionChange) *corev1.Event {
+	c := change.condition
+
+	message := fmt.Sprintf("Node condition %s is %s", c.Type, c.Status)
+	if change.resolved {
+		message = fmt.Sprintf("Node condition %s cleared (now %s)", c.Type, c.Status)
+	}
+	if c.Message != "" {
+		message += ": " + c.Message
+	}
+
+	transitioned := c.LastTransitionTime
+	if transitioned.IsZero() {
+		transitioned = metav1.Now()
+	}
+
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s.%s.%d", node.Name, c.Type, transitioned.Unix()),
+			UID:  types.UID(fmt.Sprintf("%s/%s/%d", node.UID, c.Type, transitioned.Unix())),
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Node",
+			Name: node.Name,
+			UID:  node.UID,
+		},
+		Reason:         change.reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Count:          1,
+		Source:         corev1.EventSource{Component: "kube-sentry-events", Host: node.Name},
+		FirstTimestamp: transitioned,
+		LastTimestamp:  transitioned,
+	}
+}
+
+// sendResolved resolves the condition's issue if one is open, and sends a
+// resolution log if its reason is monitored.
+func (w *Watcher) sendResolved(ctx context.Context, event *corev1.Event, details *sentry.NodeCondition) {
+	wl := workload.Workload{Kind: "Node", Name: event.InvolvedObject.Name}
+	if issue, ok := w.tracker.Get(recovery.Key(sentry.Fingerprint("", wl, event.Reason))); ok {
+		w.resolveIssue(ctx, issue)
+	}
+
+	if !w.filter.Load().ShouldProcess(event) {
+		return
+	}
+	w.logger.Info("node condition cleared",
+		"node", event.InvolvedObject.Name,
+		"reason", event.Reason,
+	)
+	w.sender.Send(sentry.EventData{
+		Event:     event,
+		Severity:  sentrygo.LevelInfo,
+		Count:     1,
+		FirstSeen: event.LastTimestamp.Time,
+		LastSeen:  event.LastTimestamp.Time,
+		Workload:  wl,
+		Node:      details,
+		Resolved:  true,
+	})
+}
+
+// podsOnNode lists up to maxNodePods pods scheduled on the node as namespace/name.
+func (w *Watcher) podsOnNode(ctx context.Context, nodeName string) []string {
+	pods, err := w.client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+		Limit:         maxNodePods,
+	})
+	if err != nil {
+		w.logger.Debug("failed to list pods on node", "node", nodeName, "error", err)
+		return nil
+	}
+
+	names := make([]string, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names
+}
+
+func resourceStrings(resources corev1.ResourceList) map[string]string {
+	result := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		result[string(name)] = quantity.String()
+	}
+	return result
+}
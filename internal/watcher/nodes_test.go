@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/filter"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+func newConditionNode(ready, memoryPressure corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "node-uid"},
+		Status: corev1.NodeStatus{
+			Capacity:    corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+			Allocatable: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("7Gi")},
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: ready},
+				{Type: corev1.NodeMemoryPressure, Status: memoryPressure},
+			},
+		},
+	}
+}
+
+func TestNodeConditionChanges(t *testing.T) {
+	healthy := newConditionNode(corev1.ConditionTrue, corev1.ConditionFalse)
+
+	changes := nodeConditionChanges(healthy, newConditionNode(corev1.ConditionUnknown, corev1.ConditionTrue))
+	if len(changes) != 2 || changes[0].reason != ReasonNodeNotReady || changes[1].reason != ReasonNodeMemoryPressure {
+		t.Fatalf("expected NotReady and MemoryPressure, got %+v", changes)
+	}
+	if changes[0].resolved || changes[1].resolved {
+		t.Error("expected conditions turning bad not to be resolved")
+	}
+
+	changes = nodeConditionChanges(newConditionNode(corev1.ConditionFalse, corev1.ConditionFalse), healthy)
+	if len(changes) != 1 || !changes[0].resolved {
+		t.Errorf("expected Ready recovery to be resolved, got %+v", changes)
+	}
+
+	if changes := nodeConditionChanges(healthy, healthy.DeepCopy()); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
+
+func TestWatcher_NodeConditionLifecycle(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+	}
+	w, sender := newTestWatcher(pod)
+	// Namespace filters must not reject cluster-scoped node events
+	w.SetFilter(filter.New([]string{"default"}, nil, []string{ReasonNodeNotReady}, nil))
+	ctx := context.Background()
+
+	healthy := newConditionNode(corev1.ConditionTrue, corev1.ConditionFalse)
+	notReady := newConditionNode(corev1.ConditionFalse, corev1.ConditionFalse)
+
+	w.handleNodeUpdate(ctx, healthy, notReady)
+	if sender.issues() != 1 {
+		t.Fatalf("expected NotReady to create an issue, got %d", sender.issues())
+	}
+	issue := sender.sent[0]
+	if issue.Node == nil || issue.Node.Allocatable["memory"] != "7Gi" || issue.Node.Capacity["memory"] != "8Gi" {
+		t.Errorf("expected capacity and allocatable in issue, got %+v", issue.Node)
+	}
+	if len(issue.Node.Pods) != 1 || issue.Node.Pods[0] != "default/web-1" {
+		t.Errorf("expected affected pods in issue, got %v", issue.Node.Pods)
+	}
+	if got := sentry.Fingerprint("", issue.Workload, issue.Event.Reason); got[3] != "node-1" || got[4] != ReasonNodeNotReady {
+		t.Errorf("expected per node and condition fingerprint, got %v", got)
+	}
+
+	w.handleNodeUpdate(ctx, notReady, healthy)
+	if len(sender.sent) != 2 || !sender.sent[1].Resolved || sender.sent[1].MeetsThreshold {
+		t.Fatalf("expected a resolution log, got %+v", sender.sent[len(sender.sent)-1])
+	}
+}
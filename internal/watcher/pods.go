@@ -0,0 +1,219 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+// Reasons of events synthesised from Pod status. OOMKilled matches the
+// kubelet's terminated reason so it deduplicates with OOMKilled Events.
+const (
+	ReasonOOMKilled          = "OOMKilled"
+	ReasonContainerCrashed   = "ContainerCrashed"
+	ReasonContainerRestarted = "ContainerRestarted"
+)
+
+// termination is a container exit found by comparing two Pod versions.
+type termination struct {
+	status     corev1.ContainerStatus
+	terminated *corev1.ContainerStateTerminated
+}
+
+// handlePodUpdate synthesises events for containers that terminated between
+// the old and new Pod status and runs them through the usual pipeline.
+func (w *Watcher) handlePodUpdate(ctx context.Context, oldObj, newObj interface{}) {
+	oldPod, ok := oldObj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	newPod, ok := newObj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+
+	for _, t := range podTerminations(oldPod, newPod) {
+		event, container := syntheticEvent(newPod, t)
+		w.process(ctx, event, sentry.EventData{Container: container})
+	}
+}
+
+// podTerminations returns the containers that restarted or terminated in newPod
+// since oldPod. Restarts are detected from a restart count increase (using the
+// last termination state), and terminations without restart (restartPolicy
+// Never/OnFailure) from a new failed terminated state. Successful exits without
+// a restart are ignored.
+func podTerminations(oldPod, newPod *corev1.Pod) []termination {
+	oldStatuses := make(map[string]corev1.ContainerStatus)
+	for _, cs := range allContainerStatuses(oldPod) {
+		oldStatuses[cs.Name] = cs
+	}
+
+	var result []termination
+	for _, cs := range allContainerStatuses(newPod) {
+		old, known := oldStatuses[cs.Name]
+
+		if known && cs.RestartCount > old.RestartCount && cs.LastTerminationState.Terminated != nil {
+			result = append(result, termination{status: cs, terminated: cs.LastTerminationState.Terminated})
+			continue
+		}
+
+		terminated := cs.State.Terminated
+		if terminated == nil || terminated.ExitCode == 0 {
+			continue
+		}
+		if known && old.State.Terminated != nil && old.State.Terminated.ContainerID == terminated.ContainerID {
+			continue
+		}
+		result = append(result, termination{status: cs, terminated: terminated})
+	}
+	return result
+}
+
+func allContainerStatuses(pod *corev1.Pod) []corev1.ContainerStatus {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	return append(statuses, pod.Status.ContainerStatuses...)
+}
+
+// syntheticEvent builds a core Event describing the termination so the filter,
+// deduplicator and sender can treat it like one reported by the kubelet.
+func syntheticEvent(pod *corev1.Pod, t termination) (*corev1.Event, *sentry.ContainerTermination) {
+	terminated := t.terminated
+
+	reason := ReasonContainerCrashed
+	switch {
+	case terminated.Reason == "OOMKilled":
+		reason = ReasonOOMKilled
+	case terminated.ExitCode == 0:
+		reason = ReasonContainerRestarted
+	}
+
+	container := &sentry.ContainerTermination{
+		Name:         t.status.Name,
+		Image:        t.status.Image,
+		Reason:       terminated.Reason,
+		ExitCode:     terminated.ExitCode,
+		Signal:       signal(terminated),
+		RestartCount: t.status.RestartCount,
+	}
+	setSpec(container, pod)
+
+	finishedAt := terminated.FinishedAt
+	if finishedAt.IsZero() {
+		finishedAt = metav1.Now()
+	}
+
+	message := fmt.Sprintf("Container %s terminated with exit code %d", t.status.Name, terminated.ExitCode)
+	if terminated.Reason != "" {
+		message += " (" + terminated.Reason + ")"
+	}
+	if terminated.Message != "" {
+		message += ": " + terminated.Message
+	}
+
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pod.Namespace,
+			Name:      fmt.Sprintf("%s.%s.%d", pod.Name, t.status.Name, t.status.RestartCount),
+			UID:       types.UID(fmt.Sprintf("%s/%s/%d", pod.UID, t.status.Name, t.status.RestartCount)),
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       pod.UID,
+			FieldPath: fmt.Sprintf("spec.containers{%s}", t.status.Name),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           corev1.EventTypeWarning,
+		Count:          max(t.status.RestartCount, 1),
+		Source:         corev1.EventSource{Component: "kube-sentry-events", Host: pod.Spec.NodeName},
+		FirstTimestamp: finishedAt,
+		LastTimestamp:  finishedAt,
+	}
+	return event, container
+}
+
+// podContainer describes an Event's container from the pod informer's cache,
+// so issues for kubelet Events (e.g. BackOff) carry the same container details
+// as those synthesised from Pod status. It's nil if the container is unknown.
+func (w *Watcher) podContainer(namespace string, event *corev1.Event) *sentry.ContainerTermination {
+	name := sentry.ContainerName(event, nil)
+	if event.InvolvedObject.Kind != "Pod" || name == "" {
+		return nil
+	}
+	pod := w.lookupPod(namespace, event.InvolvedObject.Name)
+	if pod == nil {
+		return nil
+	}
+
+	container := &sentry.ContainerTermination{Name: name}
+	for _, cs := range allContainerStatuses(pod) {
+		if cs.Name != name {
+			continue
+		}
+		container.Image = cs.Image
+		container.RestartCount = cs.RestartCount
+		if terminated := cs.LastTerminationState.Terminated; terminated != nil {
+			container.Reason = terminated.Reason
+			container.ExitCode = terminated.ExitCode
+			container.Signal = signal(terminated)
+		}
+	}
+	setSpec(container, pod)
+	return container
+}
+
+// signal returns the signal that killed the container, or zero.
+func signal(terminated *corev1.ContainerStateTerminated) int32 {
+	// Exit codes above 128 conventionally mean "killed by signal (code - 128)"
+	if terminated.Signal == 0 && terminated.ExitCode > 128 {
+		return terminated.ExitCode - 128
+	}
+	return terminated.Signal
+}
+
+// setSpec fills in the container's image and resources from the pod spec.
+func setSpec(container *sentry.ContainerTermination, pod *corev1.Pod) {
+	spec := containerSpec(pod, container.Name)
+	if spec == nil {
+		return
+	}
+	// The spec keeps the tag as written; the status may only have a digest
+	container.Image = spec.Image
+	container.Resources = resources(spec.Resources)
+	if limit, ok := spec.Resources.Limits[corev1.ResourceMemory]; ok {
+		container.MemoryLimit = limit.String()
+	}
+}
+
+// containerSpec returns the pod's container or init container named name, or
+// nil if there's none.
+func containerSpec(pod *corev1.Pod, name string) *corev1.Container {
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// resources flattens requests and limits into e.g. "limits.memory" -> "256Mi".
+func resources(r corev1.ResourceRequirements) map[string]string {
+	result := make(map[string]string, len(r.Requests)+len(r.Limits))
+	for name, quantity := range r.Requests {
+		result["requests."+string(name)] = quantity.String()
+	}
+	for name, quantity := range r.Limits {
+		result["limits."+string(name)] = quantity.String()
+	}
+	return result
+}
@@ -0,0 +1,183 @@
+package watcher
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/filter"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+)
+
+func newStatusPod(statuses ...corev1.ContainerStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "worker-79c6dd4b57-wcdzt", UID: "pod-uid"},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{{
+				Name:  "app",
+				Image: "registry.example.com/worker:v1.4.2",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: statuses},
+	}
+}
+
+func restartedStatus(restarts int32, reason string, exitCode int32) corev1.ContainerStatus {
+	cs := corev1.ContainerStatus{Name: "app", RestartCount: restarts}
+	if reason != "" || exitCode != 0 {
+		cs.LastTerminationState.Terminated = &corev1.ContainerStateTerminated{Reason: reason, ExitCode: exitCode}
+	}
+	return cs
+}
+
+func TestPodTerminations(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new *corev1.Pod
+		expected string
+	}{
+		{"oom kill", newStatusPod(restartedStatus(0, "", 0)), newStatusPod(restartedStatus(1, "OOMKilled", 137)), ReasonOOMKilled},
+		{"crash", newStatusPod(restartedStatus(2, "Error", 1)), newStatusPod(restartedStatus(3, "Error", 1)), ReasonContainerCrashed},
+		{"clean restart", newStatusPod(restartedStatus(0, "", 0)), newStatusPod(restartedStatus(1, "Completed", 0)), ReasonContainerRestarted},
+		{"no change", newStatusPod(restartedStatus(1, "Error", 1)), newStatusPod(restartedStatus(1, "Error", 1)), ""},
+		{
+			"failed without restart",
+			newStatusPod(corev1.ContainerStatus{Name: "app"}),
+			newStatusPod(corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 2, ContainerID: "c1"},
+			}}),
+			ReasonContainerCrashed,
+		},
+		{
+			"successful exit without restart",
+			newStatusPod(corev1.ContainerStatus{Name: "app"}),
+			newStatusPod(corev1.ContainerStatus{Name: "app", State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: "Completed", ContainerID: "c1"},
+			}}),
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terminations := podTerminations(tt.old, tt.new)
+			if tt.expected == "" {
+				if len(terminations) != 0 {
+					t.Errorf("expected no terminations, got %d", len(terminations))
+				}
+				return
+			}
+			if len(terminations) != 1 {
+				t.Fatalf("expected 1 termination, got %d", len(terminations))
+			}
+			event, _ := syntheticEvent(tt.new, terminations[0])
+			if event.Reason != tt.expected {
+				t.Errorf("expected reason %s, got %s", tt.expected, event.Reason)
+			}
+		})
+	}
+}
+
+func TestSyntheticEvent(t *testing.T) {
+	pod := newStatusPod(restartedStatus(4, "Error", 143))
+
+	event, container := syntheticEvent(pod, podTerminations(newStatusPod(restartedStatus(3, "", 0)), pod)[0])
+
+	if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != pod.Name {
+		t.Errorf("unexpected involved object %+v", event.InvolvedObject)
+	}
+	if event.Type != corev1.EventTypeWarning || event.Count != 4 || event.Source.Host != "node-1" {
+		t.Errorf("unexpected event fields: type=%s count=%d host=%s", event.Type, event.Count, event.Source.Host)
+	}
+	if container.ExitCode != 143 || container.Signal != 15 {
+		t.Errorf("expected exit code 143 and signal 15, got %d and %d", container.ExitCode, container.Signal)
+	}
+	if container.RestartCount != 4 || container.MemoryLimit != "256Mi" {
+		t.Errorf("expected restart count 4 and limit 256Mi, got %d and %s", container.RestartCount, container.MemoryLimit)
+	}
+	if container.Image != "registry.example.com/worker:v1.4.2" || container.Resources["limits.memory"] != "256Mi" {
+		t.Errorf("expected image and resources from the spec, got %s and %v", container.Image, container.Resources)
+	}
+}
+
+func TestWatcher_PodStatusSharesPipeline(t *testing.T) {
+	w, sender := newTestWatcher()
+	w.SetFilter(filter.New(nil, nil, []string{"CrashLoopBackOff", ReasonOOMKilled}, nil))
+	ctx := context.Background()
+
+	oldPod := newStatusPod(restartedStatus(0, "", 0))
+	newPod := newStatusPod(restartedStatus(1, "OOMKilled", 137))
+	w.handlePodUpdate(ctx, oldPod, newPod)
+
+	if sender.issues() != 1 {
+		t.Fatalf("expected OOM kill to create an issue, got %d", sender.issues())
+	}
+	if c := sender.sent[0].Container; c == nil || c.Name != "app" {
+		t.Errorf("expected container details to be attached, got %+v", c)
+	}
+
+	// A second OOM kill of the same workload is deduplicated like any other event
+	w.handlePodUpdate(ctx, newPod, newStatusPod(restartedStatus(2, "OOMKilled", 137)))
+	if sender.issues() != 1 {
+		t.Errorf("expected repeated OOM kill to be deduplicated, got %d issues", sender.issues())
+	}
+}
+
+func TestWatcher_KubeletEventGetsContainerFromPodCache(t *testing.T) {
+	pod := newStatusPod(restartedStatus(3, "Error", 1))
+	w, sender := newTestWatcher(pod)
+	w.SetFilter(filter.New(nil, nil, []string{"BackOff"}, nil))
+	startPods(t, w)
+
+	event := newWatchedEvent("a", "100", time.Now())
+	event.Reason = "BackOff"
+	event.InvolvedObject.FieldPath = "spec.containers{app}"
+	w.processEvent(context.Background(), event)
+
+	if sender.issues() != 1 {
+		t.Fatalf("expected BackOff to create an issue, got %d", sender.issues())
+	}
+	want := &sentry.ContainerTermination{
+		Name:         "app",
+		Image:        "registry.example.com/worker:v1.4.2",
+		Reason:       "Error",
+		ExitCode:     1,
+		RestartCount: 3,
+		MemoryLimit:  "256Mi",
+		Resources:    map[string]string{"limits.memory": "256Mi"},
+	}
+	if c := sender.sent[0].Container; !reflect.DeepEqual(c, want) {
+		t.Errorf("expected %+v, got %+v", want, c)
+	}
+}
+
+func TestWatcher_RunWatchesPodStatus(t *testing.T) {
+	pod := newStatusPod(restartedStatus(0, "", 0))
+	w, sender := newTestWatcher(pod)
+	w.SetFilter(filter.New(nil, nil, []string{ReasonOOMKilled}, nil))
+	w.SetWatchPodStatus(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = w.Run(ctx)
+	}()
+
+	// Keep OOM killing the container until the pod informer's watch picks it up;
+	// kills landing in the initial list are ignored, later ones are deduplicated
+	restarts := int32(0)
+	waitFor(t, func() bool {
+		restarts++
+		_, err := w.client.CoreV1().Pods("default").UpdateStatus(ctx, newStatusPod(restartedStatus(restarts, "OOMKilled", 137)), metav1.UpdateOptions{})
+		return err == nil && sender.issues() == 1
+	})
+}
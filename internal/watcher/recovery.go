@@ -0,0 +1,282 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/recovery"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+// IssueResolver resolves Sentry issues, e.g. through the Sentry Web API.
+type IssueResolver interface {
+	ResolveEvent(ctx context.Context, eventID string) error
+}
+
+// SetRecovery enables auto-resolution. Every interval, the workloads of issues
+// in tracker are checked and, once healthy, a recovery log is sent and the
+// issue is resolved through resolver. A nil resolver only sends the log.
+func (w *Watcher) SetRecovery(tracker *recovery.Tracker, resolver IssueResolver, interval time.Duration) {
+	w.tracker = tracker
+	w.issueResolver = resolver
+	w.recoveryInterval = interval
+}
+
+// runRecovery checks open issues for recovery until the context is cancelled,
+// then saves them for the next run or leader.
+func (w *Watcher) runRecovery(ctx context.Context) {
+	// Never overwrite saved issues with a partial set; retry loading on each tick
+	loaded := w.loadIssues(ctx)
+
+	ticker := time.NewTicker(w.recoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if loaded {
+				saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				w.saveIssues(saveCtx)
+				cancel()
+			}
+			return
+		case <-ticker.C:
+			if !loaded {
+				if loaded = w.loadIssues(ctx); !loaded {
+					continue
+				}
+			}
+			w.checkRecoveries(ctx, time.Now())
+			w.saveIssues(ctx)
+		}
+	}
+}
+
+func (w *Watcher) loadIssues(ctx context.Context) bool {
+	if err := w.tracker.Load(ctx); err != nil {
+		w.logger.Error("failed to load open issues, will retry", "error", err)
+		return false
+	}
+	w.logger.Info("tracking open issues for recovery", "open_issues", w.tracker.Len())
+	return true
+}
+
+func (w *Watcher) saveIssues(ctx context.Context) {
+	if err := w.tracker.Save(ctx); err != nil {
+		w.logger.Error("failed to save open issues", "error", err)
+	}
+}
+
+// checkRecoveries resolves open issues whose workload is healthy again. Issues
+// younger than the check interval are skipped so the workload status has time
+// to reflect the problem.
+func (w *Watcher) checkRecoveries(ctx context.Context, now time.Time) {
+	if expired := w.tracker.Expire(now); expired > 0 {
+		w.logger.Warn("stopped tracking issues that didn't recover in time", "expired", expired)
+	}
+	for _, issue := range w.tracker.Issues() {
+		if now.Sub(issue.OpenedAt) < w.recoveryInterval {
+			continue
+		}
+
+		healthy, err := w.workloadHealthy(ctx, issue)
+		if err != nil {
+			w.logger.Debug("failed to check workload health",
+				"namespace", issue.Namespace,
+				"workload", issue.Kind+"/"+issue.Name,
+				"error", err,
+			)
+			continue
+		}
+		if !healthy || !w.resolveIssue(ctx, issue) {
+			continue
+		}
+
+		w.logger.Info("workload recovered",
+			"namespace", issue.Namespace,
+			"workload", issue.Kind+"/"+issue.Name,
+			"reason", issue.Reason,
+			"open_for", now.Sub(issue.OpenedAt).Round(time.Second),
+		)
+		w.sendRecovered(issue, now)
+	}
+}
+
+// resolveIssue resolves the issue in Sentry, if a resolver is configured, and
+// stops tracking it. It returns false if resolving failed and should be retried.
+func (w *Watcher) resolveIssue(ctx context.Context, issue recovery.Issue) bool {
+	if w.issueResolver != nil {
+		err := w.issueResolver.ResolveEvent(ctx, issue.EventID)
+		if err != nil && !errors.Is(err, sentry.ErrEventNotFound) {
+			metrics.ResolveFailures.Inc()
+			w.logger.Warn("failed to resolve sentry issue, will retry",
+				"namespace", issue.Namespace,
+				"workload", issue.Kind+"/"+issue.Name,
+				"reason", issue.Reason,
+				"error", err,
+			)
+			return false
+		}
+	}
+
+	w.tracker.Close(issue.Key())
+	// A recurrence after recovery should reopen the issue straight away
+	w.dedup.Forget(issue.Namespace, issue.Kind+"/"+issue.Name, issue.Reason)
+	metrics.IssuesResolved.Inc()
+	return true
+}
+
+// sendRecovered sends a log-only recovery notification for the issue.
+func (w *Watcher) sendRecovered(issue recovery.Issue, now time.Time) {
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: issue.Namespace,
+			Name:      fmt.Sprintf("%s.recovered.%d", issue.Name, now.Unix()),
+			UID:       types.UID(issue.Key() + "/recovered"),
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      issue.Kind,
+			Namespace: issue.Namespace,
+			Name:      issue.Name,
+		},
+		Reason:         issue.Reason,
+		Message:        fmt.Sprintf("%s/%s recovered after %s", issue.Kind, issue.Name, now.Sub(issue.OpenedAt).Round(time.Second)),
+		Type:           corev1.EventTypeNormal,
+		Count:          1,
+		Source:         corev1.EventSource{Component: "kube-sentry-events"},
+		FirstTimestamp: metav1.NewTime(issue.OpenedAt),
+		LastTimestamp:  metav1.NewTime(now),
+	}
+
+	w.sender.Send(sentry.EventData{
+		Event:     event,
+		Severity:  sentrygo.LevelInfo,
+		Count:     1,
+		FirstSeen: issue.OpenedAt,
+		LastSeen:  now,
+		Workload:  workload.Workload{Kind: issue.Kind, Name: issue.Name},
+		Project:   issue.Project,
+		Resolved:  true,
+	})
+}
+
+// workloadHealthy reports whether the issue's workload has recovered: a rollout
+// is complete with all replicas available, a Pod is Ready, a Job completed, a
+// CronJob succeeded since the issue opened, or a Node condition cleared.
+// Deleted workloads count as recovered. Only recovery.Recoverable kinds are
+// tracked, so every one of them needs a check here.
+func (w *Watcher) workloadHealthy(ctx context.Context, issue recovery.Issue) (bool, error) {
+	healthy, err := w.checkHealth(ctx, issue)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	return healthy, err
+}
+
+func (w *Watcher) checkHealth(ctx context.Context, issue recovery.Issue) (bool, error) {
+	opts := metav1.GetOptions{}
+	switch issue.Kind {
+	case "Deployment":
+		d, err := w.client.AppsV1().Deployments(issue.Namespace).Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		return deploymentHealthy(d), nil
+	case "StatefulSet":
+		s, err := w.client.AppsV1().StatefulSets(issue.Namespace).Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		replicas := replicasOrDefault(s.Spec.Replicas)
+		return s.Status.ObservedGeneration >= s.Generation &&
+			s.Status.UpdatedReplicas == replicas &&
+			s.Status.AvailableReplicas == replicas, nil
+	case "DaemonSet":
+		ds, err := w.client.AppsV1().DaemonSets(issue.Namespace).Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		return ds.Status.ObservedGeneration >= ds.Generation &&
+			ds.Status.UpdatedNumberScheduled == ds.Status.DesiredNumberScheduled &&
+			ds.Status.NumberAvailable == ds.Status.DesiredNumberScheduled, nil
+	case "ReplicaSet":
+		rs, err := w.client.AppsV1().ReplicaSets(issue.Namespace).Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		return rs.Status.AvailableReplicas == replicasOrDefault(rs.Spec.Replicas), nil
+	case "Pod":
+		pod, err := w.client.CoreV1().Pods(issue.Namespace).Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		return podReady(pod), nil
+	case "Job":
+		job, err := w.client.BatchV1().Jobs(issue.Namespace).Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		for _, c := range job.Status.Conditions {
+			if c.Type == batchv1.JobComplete && c.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "CronJob":
+		cj, err := w.client.BatchV1().CronJobs(issue.Namespace).Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		return cj.Status.LastSuccessfulTime != nil && cj.Status.LastSuccessfulTime.After(issue.OpenedAt), nil
+	case "Node":
+		node, err := w.client.CoreV1().Nodes().Get(ctx, issue.Name, opts)
+		if err != nil {
+			return false, err
+		}
+		for _, c := range node.Status.Conditions {
+			if nodeConditionReasons[c.Type] == issue.Reason {
+				return !isBadCondition(c), nil
+			}
+		}
+		return false, nil
+	default:
+		return false, nil
+	}
+}
+
+// deploymentHealthy returns true once the latest rollout is complete and every replica is available.
+func deploymentHealthy(d *appsv1.Deployment) bool {
+	replicas := replicasOrDefault(d.Spec.Replicas)
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == replicas &&
+		d.Status.Replicas == replicas &&
+		d.Status.AvailableReplicas == replicas
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func replicasOrDefault(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
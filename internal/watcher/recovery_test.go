@@ -0,0 +1,194 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/imankulov/kube-sentry-events/internal/health"
+	"github.com/imankulov/kube-sentry-events/internal/recovery"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+	"github.com/imankulov/kube-sentry-events/internal/state"
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+// recordingResolver records resolved events and fails for events in errs.
+type recordingResolver struct {
+	mu       sync.Mutex
+	resolved []string
+	errs     map[string]error
+}
+
+func (r *recordingResolver) ResolveEvent(_ context.Context, eventID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.errs[eventID]; err != nil {
+		return err
+	}
+	r.resolved = append(r.resolved, eventID)
+	return nil
+}
+
+func newTrackedIssue(namespace, kind, name, reason string, openedAt time.Time) recovery.Issue {
+	return recovery.Issue{
+		Fingerprint: sentry.Fingerprint(namespace, workload.Workload{Kind: kind, Name: name}, reason),
+		Namespace:   namespace,
+		Kind:        kind,
+		Name:        name,
+		Reason:      reason,
+		EventID:     "event-" + name,
+		OpenedAt:    openedAt,
+	}
+}
+
+func newDeployment(name string, available int32) *appsv1.Deployment {
+	replicas := int32(2)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Generation: 3},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 3,
+			Replicas:           2,
+			UpdatedReplicas:    2,
+			AvailableReplicas:  available,
+		},
+	}
+}
+
+func TestWatcher_CheckRecoveries(t *testing.T) {
+	w, sender := newTestWatcher(newDeployment("web", 2), newDeployment("api", 1))
+	tracker := recovery.NewTracker(nil)
+	resolver := &recordingResolver{}
+	w.SetRecovery(tracker, resolver, time.Minute)
+
+	now := time.Now()
+	old := now.Add(-10 * time.Minute)
+	tracker.Open(newTrackedIssue("default", "Deployment", "web", "CrashLoopBackOff", old))
+	tracker.Open(newTrackedIssue("default", "Deployment", "api", "CrashLoopBackOff", old))
+	tracker.Open(newTrackedIssue("default", "Pod", "deleted-pod", "OOMKilled", old))
+	tracker.Open(newTrackedIssue("default", "Deployment", "fresh", "CrashLoopBackOff", now))
+	w.dedup.Check("default", "Deployment/web", "CrashLoopBackOff")
+
+	w.checkRecoveries(context.Background(), now)
+
+	if len(resolver.resolved) != 2 {
+		t.Errorf("expected healthy and deleted workloads to be resolved, got %v", resolver.resolved)
+	}
+	if tracker.Len() != 2 {
+		t.Errorf("expected unhealthy and fresh issues to stay open, got %+v", tracker.Issues())
+	}
+	if len(sender.sent) != 2 || !sender.sent[0].Resolved || sender.sent[0].MeetsThreshold {
+		t.Fatalf("expected log-only recovery notifications, got %+v", sender.sent)
+	}
+	if isNew, _, _, _ := w.dedup.Check("default", "Deployment/web", "CrashLoopBackOff"); !isNew {
+		t.Error("expected a recurrence after recovery to create a new issue")
+	}
+}
+
+func TestWatcher_ChecksEveryRecoverableKind(t *testing.T) {
+	w, _ := newTestWatcher()
+
+	// With nothing in the cluster, every checked kind looks its workload up
+	for _, kind := range []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Pod", "Job", "CronJob", "Node"} {
+		if !recovery.Recoverable(kind) {
+			t.Errorf("expected %s to be recoverable", kind)
+		}
+		issue := newTrackedIssue("default", kind, "gone", "CrashLoopBackOff", time.Now())
+		if _, err := w.checkHealth(context.Background(), issue); !apierrors.IsNotFound(err) {
+			t.Errorf("%s: expected a lookup, got %v", kind, err)
+		}
+	}
+	if recovery.Recoverable("Rollout") {
+		t.Error("expected custom resources not to be recoverable")
+	}
+}
+
+func TestWatcher_CheckRecoveriesRetriesFailedResolve(t *testing.T) {
+	w, sender := newTestWatcher(newDeployment("web", 2), newDeployment("api", 2))
+	tracker := recovery.NewTracker(nil)
+	resolver := &recordingResolver{errs: map[string]error{
+		"event-web": errors.New("503 Service Unavailable"),
+		"event-api": sentry.ErrEventNotFound,
+	}}
+	w.SetRecovery(tracker, resolver, time.Minute)
+
+	old := time.Now().Add(-time.Hour)
+	tracker.Open(newTrackedIssue("default", "Deployment", "web", "CrashLoopBackOff", old))
+	tracker.Open(newTrackedIssue("default", "Deployment", "api", "CrashLoopBackOff", old))
+
+	w.checkRecoveries(context.Background(), time.Now())
+
+	if issues := tracker.Issues(); len(issues) != 1 || issues[0].Name != "web" {
+		t.Errorf("expected only the failed resolve to be retried, got %+v", issues)
+	}
+	if len(sender.sent) != 1 {
+		t.Errorf("expected recovery log only for the closed issue, got %d", len(sender.sent))
+	}
+}
+
+func TestWatcher_CheckRecoveriesWithoutResolver(t *testing.T) {
+	w, sender := newTestWatcher(newDeployment("web", 2))
+	tracker := recovery.NewTracker(nil)
+	w.SetRecovery(tracker, nil, time.Minute)
+	tracker.Open(newTrackedIssue("default", "Deployment", "web", "CrashLoopBackOff", time.Now().Add(-time.Hour)))
+
+	w.checkRecoveries(context.Background(), time.Now())
+
+	if tracker.Len() != 0 || len(sender.sent) != 1 {
+		t.Errorf("expected recovery to be logged without a resolver, got %d open and %d sent", tracker.Len(), len(sender.sent))
+	}
+}
+
+func TestWatcher_NodeResolutionResolvesIssue(t *testing.T) {
+	w, _ := newTestWatcher()
+	tracker := recovery.NewTracker(nil)
+	resolver := &recordingResolver{}
+	w.SetRecovery(tracker, resolver, time.Minute)
+	tracker.Open(newTrackedIssue("", "Node", "node-1", ReasonNodeNotReady, time.Now()))
+
+	healthy := newConditionNode(corev1.ConditionTrue, corev1.ConditionFalse)
+	notReady := newConditionNode(corev1.ConditionFalse, corev1.ConditionFalse)
+	w.handleNodeUpdate(context.Background(), notReady, healthy)
+
+	if tracker.Len() != 0 || len(resolver.resolved) != 1 {
+		t.Errorf("expected node recovery to resolve the issue, got %d open, resolved %v", tracker.Len(), resolver.resolved)
+	}
+}
+
+func TestWatcher_RunPersistsOpenIssues(t *testing.T) {
+	w, _ := newTestWatcher()
+	checker := health.New(time.Minute)
+	checker.SetSentryReady(true)
+	w.SetHealth(checker)
+	store := state.NewConfigMapStore(w.client, "monitoring", "kube-sentry-events-state", "open-issues.json")
+	tracker := recovery.NewTracker(store)
+	w.SetRecovery(tracker, nil, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+	waitFor(t, func() bool { return checker.Ready() == nil })
+
+	issue := newTrackedIssue("default", "Deployment", "web", "CrashLoopBackOff", time.Now())
+	tracker.Open(issue)
+	cancel()
+	<-done
+
+	// A new process (or leader) picks the issue up again
+	restarted := recovery.NewTracker(store)
+	if err := restarted.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := restarted.Get(issue.Key()); !ok {
+		t.Errorf("expected open issue to be saved on shutdown, got %+v", restarted.Issues())
+	}
+}
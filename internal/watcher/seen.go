@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// seenTracker remembers the last processed resourceVersion of every event UID.
+// After a relist (informer start, 410 Gone, API server restart) the informer
+// re-delivers every event it knows about; the tracker lets us skip the ones we
+// have already processed so they don't re-alert.
+type seenTracker struct {
+	mu       sync.Mutex
+	versions map[types.UID]string
+}
+
+func newSeenTracker() *seenTracker {
+	return &seenTracker{
+		versions: make(map[types.UID]string),
+	}
+}
+
+// markProcessed records the event and returns true if this version of it has
+// not been processed before.
+func (s *seenTracker) markProcessed(event *corev1.Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rv, ok := s.versions[event.UID]; ok && rv == event.ResourceVersion {
+		return false
+	}
+	s.versions[event.UID] = event.ResourceVersion
+	return true
+}
+
+// forget drops a deleted event from the tracker.
+func (s *seenTracker) forget(uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.versions, uid)
+}
+
+// size returns the number of tracked events.
+func (s *seenTracker) size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.versions)
+}
@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newTrackedEvent(uid, resourceVersion string) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:             types.UID(uid),
+			ResourceVersion: resourceVersion,
+		},
+	}
+}
+
+func TestSeenTracker_SkipsReplayedVersion(t *testing.T) {
+	s := newSeenTracker()
+
+	if !s.markProcessed(newTrackedEvent("a", "100")) {
+		t.Error("expected first delivery to be processed")
+	}
+
+	// Relist re-delivers the same version
+	if s.markProcessed(newTrackedEvent("a", "100")) {
+		t.Error("expected replayed version to be skipped")
+	}
+}
+
+func TestSeenTracker_ProcessesNewVersion(t *testing.T) {
+	s := newSeenTracker()
+
+	s.markProcessed(newTrackedEvent("a", "100"))
+
+	// Event count bumped by the kubelet -> new resourceVersion
+	if !s.markProcessed(newTrackedEvent("a", "101")) {
+		t.Error("expected updated event to be processed")
+	}
+}
+
+func TestSeenTracker_Forget(t *testing.T) {
+	s := newSeenTracker()
+
+	s.markProcessed(newTrackedEvent("a", "100"))
+	s.markProcessed(newTrackedEvent("b", "200"))
+	s.forget("a")
+
+	if s.size() != 1 {
+		t.Errorf("expected 1 tracked event, got %d", s.size())
+	}
+	if !s.markProcessed(newTrackedEvent("a", "100")) {
+		t.Error("expected forgotten event to be processed again")
+	}
+}
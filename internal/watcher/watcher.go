@@ -4,18 +4,40 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 
+	sentrygo "github.com/getsentry/sentry-go"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 
 	"github.com/imankulov/kube-sentry-events/internal/dedup"
 	"github.com/imankulov/kube-sentry-events/internal/filter"
+	"github.com/imankulov/kube-sentry-events/internal/health"
+	"github.com/imankulov/kube-sentry-events/internal/manifest"
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/recovery"
 	"github.com/imankulov/kube-sentry-events/internal/sentry"
+	"github.com/imankulov/kube-sentry-events/internal/workload"
+)
+
+const (
+	// workloadCacheTTL is how long ownerReference lookups are cached.
+	workloadCacheTTL = 10 * time.Minute
+	// manifestCacheTTL is how long manifest snapshots are cached.
+	manifestCacheTTL = time.Minute
+	// manifestTimeout bounds fetching a manifest, so a slow API server
+	// doesn't hold up the event behind it.
+	manifestTimeout = 5 * time.Second
 )
 
 // EventSender is the interface for sending events (Sentry or dry-run).
@@ -25,48 +47,234 @@ type EventSender interface {
 
 // Watcher watches Kubernetes events and sends them to Sentry.
 type Watcher struct {
-	client kubernetes.Interface
-	filter *filter.Filter
-	dedup  *dedup.Deduplicator
-	sender EventSender
-	logger *slog.Logger
+	client   kubernetes.Interface
+	resolver *workload.Resolver
+	manifest *manifest.Fetcher
+	filter   atomic.Pointer[filter.Filter] // Swapped on config reload
+	dedup    *dedup.Deduplicator
+	sender   EventSender
+	logger   *slog.Logger
+	seen     *seenTracker
+	health   *health.Checker
+
+	// watchPodStatus enables synthesising events from container terminations
+	watchPodStatus bool
+	// watchNodes enables synthesising events from Node condition transitions
+	watchNodes bool
+	// watchJobs enables synthesising events from failed Job conditions
+	watchJobs bool
+
+	// tracker, issueResolver and recoveryInterval configure auto-resolution;
+	// a nil tracker disables it
+	tracker          *recovery.Tracker
+	issueResolver    IssueResolver
+	recoveryInterval time.Duration
+
+	// snapshotInterval is how often dedup state is saved; zero disables saving
+	snapshotInterval time.Duration
+
+	// escalateFlapping raises the severity of flapping events by one level
+	escalateFlapping bool
+
+	// logs configures attaching container logs to issues; swapped on config
+	// reload, nil disables it
+	logs atomic.Pointer[logSettings]
+
+	// manifestReasons are the reasons whose issues get the involved object's
+	// manifest attached; swapped on config reload
+	manifestReasons atomic.Pointer[map[string]struct{}]
+
+	// namespaces is a cached lister for namespace labels and annotations,
+	// set up by RunSince and ListOnce before any event is processed
+	namespaces corelisters.NamespaceLister
+	// pods is the pod informer's lister, set up by RunSince if pod status is
+	// watched; nil otherwise
+	pods corelisters.PodLister
+	// jobs is the Job informer's lister, set up by RunSince if Jobs are
+	// watched; nil otherwise
+	jobs batchlisters.JobLister
 }
 
 // New creates a new event watcher.
-func New(f *filter.Filter, d *dedup.Deduplicator, s EventSender, logger *slog.Logger, kubeconfigPath string) (*Watcher, error) {
-	client, err := createK8sClient(kubeconfigPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+func New(f *filter.Filter, d *dedup.Deduplicator, s EventSender, logger *slog.Logger, client kubernetes.Interface) *Watcher {
+	w := &Watcher{
+		client:   client,
+		resolver: workload.NewResolver(client, workloadCacheTTL),
+		manifest: manifest.NewFetcher(client, manifestCacheTTL),
+		dedup:    d,
+		sender:   s,
+		logger:   logger,
+		seen:     newSeenTracker(),
 	}
+	w.filter.Store(f)
+	return w
+}
+
+// SetFilter atomically replaces the filter, e.g. after a config reload.
+// Deduplication state is kept.
+func (w *Watcher) SetFilter(f *filter.Filter) {
+	w.filter.Store(f)
+}
+
+// SetHealth attaches a health checker that tracks watch activity.
+func (w *Watcher) SetHealth(h *health.Checker) {
+	w.health = h
+}
+
+// SetWatchPodStatus enables the Pod status watcher, which detects container
+// terminations (OOM kills, crashes, restarts) that aren't reported as Events.
+func (w *Watcher) SetWatchPodStatus(enabled bool) {
+	w.watchPodStatus = enabled
+}
+
+// SetWatchNodes enables the Node watcher, which raises issues when a node
+// condition turns bad and logs a resolution when it clears.
+func (w *Watcher) SetWatchNodes(enabled bool) {
+	w.watchNodes = enabled
+}
 
-	return &Watcher{
-		client: client,
-		filter: f,
-		dedup:  d,
-		sender: s,
-		logger: logger,
-	}, nil
+// SetWatchJobs enables the Job watcher, which raises issues when a Job fails,
+// attributed to its parent CronJob.
+func (w *Watcher) SetWatchJobs(enabled bool) {
+	w.watchJobs = enabled
+}
+
+// SetSnapshotInterval saves the deduplicator's state to its store every
+// interval and on shutdown. State is restored when the watcher starts.
+func (w *Watcher) SetSnapshotInterval(interval time.Duration) {
+	w.snapshotInterval = interval
+}
+
+// SetEscalateFlapping raises the Sentry level of issues for flapping events
+// by one step, e.g. warning to error. They're tagged k8s.flapping either way.
+func (w *Watcher) SetEscalateFlapping(enabled bool) {
+	w.escalateFlapping = enabled
 }
 
 // Run starts watching for events. It blocks until the context is cancelled.
+// Events are consumed through a shared informer, which resumes from the last seen
+// resourceVersion on reconnect and relists on 410 Gone instead of dropping events.
 func (w *Watcher) Run(ctx context.Context) error {
-	w.logger.Info("starting event watcher")
+	return w.RunSince(ctx, time.Time{})
+}
 
-	for {
-		if err := w.watchEvents(ctx); err != nil {
-			if ctx.Err() != nil {
-				return ctx.Err()
+// RunSince is like Run, but events in the initial list last seen before cutoff are
+// only recorded in the deduplicator, not sent. This is used when taking over from
+// another leader, which has already alerted on them.
+func (w *Watcher) RunSince(ctx context.Context, cutoff time.Time) error {
+	w.logger.Info("starting event watcher", "cutoff", cutoff)
+
+	factory := informers.NewSharedInformerFactory(w.client, 0)
+	defer factory.Shutdown()
+
+	// Namespaces must be cached before events arrive so selectors and opt-outs apply from the start
+	if err := w.startNamespaceInformer(ctx, factory); err != nil {
+		return err
+	}
+
+	// Restore dedup state before any event is processed so ongoing problems aren't re-alerted
+	if n, err := w.dedup.Load(ctx); err != nil {
+		w.logger.Error("failed to restore dedup state", "error", err)
+	} else if n > 0 {
+		w.logger.Info("restored dedup state", "entries", n)
+	}
+
+	informer := factory.InformerFor(&corev1.Event{}, w.newEventInformer)
+
+	err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		// The reflector retries with backoff and relists if the resourceVersion expired
+		metrics.WatchReconnects.Inc()
+		metrics.WatchConnected.Set(0)
+		w.health.SetWatching(false)
+		w.logger.Error("watch error, reconnecting", "error", err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set watch error handler: %w", err)
+	}
+
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if isInInitialList && !cutoff.IsZero() {
+				w.primeEvent(ctx, obj, cutoff)
+				return
 			}
-			w.logger.Error("watch error, reconnecting", "error", err)
-			time.Sleep(5 * time.Second)
+			w.handleEvent(ctx, obj)
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			w.handleEvent(ctx, obj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if event, ok := obj.(*corev1.Event); ok {
+				w.seen.forget(event.UID)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add event handler: %w", err)
+	}
+
+	if w.watchPodStatus {
+		if err := w.addPodInformer(ctx, factory); err != nil {
+			return err
+		}
+	}
+	if w.watchNodes {
+		if err := w.addNodeInformer(ctx, factory); err != nil {
+			return err
 		}
 	}
+	if w.watchJobs {
+		if err := w.addJobInformer(ctx, factory); err != nil {
+			return err
+		}
+	}
+
+	factory.Start(ctx.Done())
+	defer w.health.SetWatching(false)
+	defer metrics.WatchConnected.Set(0)
+
+	// Issues can be created as soon as handlers run, so track them from here on
+	if w.tracker != nil {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			w.runRecovery(ctx)
+		}()
+		// Wait for open issues to be saved before returning
+		defer func() { <-done }()
+	}
+	if w.snapshotInterval > 0 {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			w.runSnapshots(ctx)
+		}()
+		defer func() { <-done }()
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return ctx.Err()
+	}
+
+	w.logger.Info("watching for kubernetes events")
+
+	<-ctx.Done()
+	return ctx.Err()
 }
 
 // ListOnce lists all current events that match the filter and exits.
 func (w *Watcher) ListOnce(ctx context.Context) error {
 	w.logger.Info("listing current events (once mode)")
 
+	factory := informers.NewSharedInformerFactory(w.client, 0)
+	defer factory.Shutdown()
+	if err := w.startNamespaceInformer(ctx, factory); err != nil {
+		return err
+	}
+
 	events, err := w.client.CoreV1().Events("").List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to list events: %w", err)
@@ -77,9 +285,9 @@ func (w *Watcher) ListOnce(ctx context.Context) error {
 	matched := 0
 	for i := range events.Items {
 		event := &events.Items[i]
-		if w.filter.ShouldProcess(event) {
+		if w.filter.Load().ShouldProcess(event) {
 			matched++
-			w.processEvent(event)
+			w.processEvent(ctx, event)
 		}
 	}
 
@@ -87,46 +295,179 @@ func (w *Watcher) ListOnce(ctx context.Context) error {
 	return nil
 }
 
-func (w *Watcher) watchEvents(ctx context.Context) error {
-	// Watch events across all namespaces
-	watcher, err := w.client.CoreV1().Events("").Watch(ctx, metav1.ListOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to create event watch: %w", err)
+// startNamespaceInformer starts the namespace informer and waits for it to sync.
+func (w *Watcher) startNamespaceInformer(ctx context.Context, factory informers.SharedInformerFactory) error {
+	namespaces := factory.Core().V1().Namespaces()
+	w.namespaces = namespaces.Lister()
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), namespaces.Informer().HasSynced) {
+		return fmt.Errorf("failed to sync namespace cache: %w", ctx.Err())
 	}
-	defer watcher.Stop()
+	return nil
+}
 
-	w.logger.Info("watching for kubernetes events")
+// runSnapshots saves dedup state every snapshotInterval and once more when
+// the context is cancelled.
+func (w *Watcher) runSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(w.snapshotInterval)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case event, ok := <-watcher.ResultChan():
-			if !ok {
-				return fmt.Errorf("watch channel closed")
+			saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := w.dedup.Save(saveCtx); err != nil {
+				w.logger.Error("failed to save dedup state", "error", err)
 			}
-
-			if event.Type == watch.Error {
-				return fmt.Errorf("watch error: %v", event.Object)
+			return
+		case <-ticker.C:
+			if err := w.dedup.Save(ctx); err != nil {
+				w.logger.Error("failed to save dedup state", "error", err)
 			}
+		}
+	}
+}
 
-			if event.Type != watch.Added && event.Type != watch.Modified {
-				continue
-			}
+// addPodInformer registers the Pod status watcher. Only updates are handled:
+// terminations already present in the initial list were seen before startup.
+func (w *Watcher) addPodInformer(ctx context.Context, factory informers.SharedInformerFactory) error {
+	informer := factory.Core().V1().Pods().Informer()
+	w.pods = factory.Core().V1().Pods().Lister()
+	// Pods are large and numerous; drop managed fields to save memory
+	err := informer.SetTransform(func(obj interface{}) (interface{}, error) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			pod.ManagedFields = nil
+		}
+		return obj, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set pod transform: %w", err)
+	}
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			w.handlePodUpdate(ctx, oldObj, newObj)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add pod handler: %w", err)
+	}
+	return nil
+}
 
-			k8sEvent, ok := event.Object.(*corev1.Event)
-			if !ok {
-				continue
+// addNodeInformer registers the Node watcher. Only updates are handled, so
+// conditions already bad at startup aren't reported again.
+func (w *Watcher) addNodeInformer(ctx context.Context, factory informers.SharedInformerFactory) error {
+	_, err := factory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			w.handleNodeUpdate(ctx, oldObj, newObj)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add node handler: %w", err)
+	}
+	return nil
+}
+
+// addJobInformer registers the Job watcher. Only updates are handled, so Jobs
+// that failed before startup aren't reported again.
+func (w *Watcher) addJobInformer(ctx context.Context, factory informers.SharedInformerFactory) error {
+	jobs := factory.Batch().V1().Jobs()
+	w.jobs = jobs.Lister()
+	_, err := jobs.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			w.handleJobUpdate(ctx, oldObj, newObj)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add job handler: %w", err)
+	}
+	return nil
+}
+
+// newEventInformer builds the event informer. Every event and bookmark received on
+// its watches is reported to the health checker so liveness can detect a stuck watch.
+func (w *Watcher) newEventInformer(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+	lw := &cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			return client.CoreV1().Events("").List(ctx, options)
+		},
+		WatchFuncWithContext: func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			wi, err := client.CoreV1().Events("").Watch(ctx, options)
+			if err != nil {
+				return nil, err
 			}
+			metrics.WatchConnected.Set(1)
+			w.health.SetWatching(true)
+			return watch.Filter(wi, func(e watch.Event) (watch.Event, bool) {
+				w.health.Touch()
+				return e, true
+			}), nil
+		},
+	}
+	// Let fake clients opt out of streaming lists, as the generated informers do
+	return cache.NewSharedIndexInformer(cache.ToListWatcherWithWatchListSemantics(lw, client), &corev1.Event{}, resync, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+}
 
-			w.processEvent(k8sEvent)
-		}
+// handleEvent processes an event delivered by the informer, skipping versions
+// that were already processed before a relist.
+func (w *Watcher) handleEvent(ctx context.Context, obj interface{}) {
+	event, ok := obj.(*corev1.Event)
+	if !ok {
+		return
+	}
+
+	if !w.seen.markProcessed(event) {
+		return
+	}
+
+	w.processEvent(ctx, event)
+}
+
+// primeEvent records an event from the initial list in the deduplicator without
+// sending it, unless it was last seen after cutoff.
+func (w *Watcher) primeEvent(ctx context.Context, obj interface{}, cutoff time.Time) {
+	event, ok := obj.(*corev1.Event)
+	if !ok {
+		return
+	}
+
+	if !eventLastSeen(event).Before(cutoff) {
+		w.handleEvent(ctx, obj)
+		return
+	}
+
+	f := w.filter.Load()
+	if !w.seen.markProcessed(event) || !f.ShouldProcess(event) {
+		return
 	}
+
+	namespace := event.InvolvedObject.Namespace
+	if namespace == "" {
+		namespace = event.Namespace
+	}
+	wl := w.resolveWorkload(ctx, namespace, event)
+	w.dedup.CheckWindow(namespace, wl.Kind+"/"+wl.Name, event.Reason, f.GetDedupWindow(event.Reason))
 }
 
-func (w *Watcher) processEvent(event *corev1.Event) {
+func (w *Watcher) processEvent(ctx context.Context, event *corev1.Event) {
+	w.process(ctx, event, sentry.EventData{})
+}
+
+// process runs an event through the filter, deduplicator and sender. Details of
+// synthesised events (container or node) are passed in data and kept.
+func (w *Watcher) process(ctx context.Context, event *corev1.Event, data sentry.EventData) {
+	metrics.EventsReceived.Inc()
+
+	// Use one filter for the whole event even if the config is reloaded meanwhile
+	f := w.filter.Load()
+
 	// Apply filter (namespace, event type, reason)
-	if !w.filter.ShouldProcess(event) {
+	if rejection := f.Check(event); rejection != filter.Accepted {
+		metrics.EventsFiltered.WithLabelValues(string(rejection)).Inc()
 		return
 	}
 
@@ -137,63 +478,277 @@ func (w *Watcher) processEvent(event *corev1.Event) {
 	podName := event.InvolvedObject.Name
 	reason := event.Reason
 
-	// Extract deployment name for dedup - this groups events across pod rollouts
-	// e.g., "worker-79c6dd4b57-wcdzt" -> "worker"
-	deployment := sentry.ExtractDeploymentName(podName)
+	// Resolve the owning workload for dedup - this groups events across pod rollouts
+	// e.g., Pod "worker-79c6dd4b57-wcdzt" -> Deployment "worker"
+	wl := w.resolveWorkload(ctx, namespace, event)
+	workloadKey := wl.Kind + "/" + wl.Name
 
-	// Get severity
-	severity := w.filter.GetSeverity(reason)
+	// Apply label selectors and opt-out annotations on the namespace and owner chain
+	ns := w.lookupNamespace(namespace)
+	if rejection := f.CheckMetadata(ns, wl.Chain); rejection != filter.Accepted {
+		metrics.EventsFiltered.WithLabelValues(string(rejection)).Inc()
+		w.logger.Debug("skipping event filtered by labels or annotations",
+			"namespace", namespace,
+			"workload", workloadKey,
+			"reason", reason,
+			"rejection", rejection,
+		)
+		return
+	}
+
+	// Merge per-workload and per-namespace annotation overrides over the global rules
+	rule, err := f.RuleFor(reason, ns, wl.Chain)
+	if err != nil {
+		w.logger.Warn("ignoring invalid override annotations",
+			"namespace", namespace,
+			"workload", workloadKey,
+			"error", err,
+		)
+	}
+	severity := rule.Severity
 
 	// Check if event meets threshold for creating an Issue
-	meetsThreshold := w.filter.MeetsThreshold(event)
+	meetsThreshold := rule.MeetsThreshold(event)
+	muted := rule.Muted(time.Now())
+
+	// Check deduplication by workload (not pod) - only applies to Issues, not Logs
+	// This aligns with Sentry fingerprinting and reduces noise across rollouts.
+	// Muted events leave the dedup state alone: recording them would open a
+	// window outlasting the mute, hiding a problem still firing once it ends.
+	var occurrence dedup.Occurrence
+	if muted {
+		occurrence = w.mutedOccurrence(namespace, workloadKey, reason)
+	} else {
+		occurrence = w.dedup.Observe(namespace, workloadKey, reason, f.GetDedupWindow(reason))
+	}
+	isNew, count := occurrence.IsNew, occurrence.Count
+	// A problem that keeps firing is reported again every renotify interval
+	reminder := occurrence.Remind && meetsThreshold && !muted
+	shouldCreateIssue := meetsThreshold && (isNew || reminder) && !muted
 
-	// Check deduplication by deployment (not pod) - only applies to Issues, not Logs
-	// This aligns with Sentry fingerprinting and reduces noise across rollouts
-	isNew, count, firstSeen, lastSeen := w.dedup.Check(namespace, deployment, reason)
-	shouldCreateIssue := meetsThreshold && isNew
+	if occurrence.Flapping && w.escalateFlapping {
+		severity = escalateLevel(severity)
+	}
+
+	if !meetsThreshold {
+		metrics.EventsBelowThreshold.Inc()
+	}
 
-	if !isNew && meetsThreshold {
+	if !isNew && !reminder && meetsThreshold && !muted {
+		metrics.EventsDeduplicated.Inc()
 		w.logger.Debug("skipping duplicate issue (log still sent)",
 			"namespace", namespace,
-			"deployment", deployment,
+			"workload", workloadKey,
 			"pod", podName,
 			"reason", reason,
 			"count", count,
 		)
 	}
 
+	if meetsThreshold && muted {
+		metrics.EventsMuted.Inc()
+		w.logger.Debug("skipping muted issue (log still sent)",
+			"namespace", namespace,
+			"workload", workloadKey,
+			"pod", podName,
+			"reason", reason,
+			"mute_until", rule.MuteUntil,
+		)
+	}
+
 	if shouldCreateIssue {
 		w.logger.Info("sending event to sentry (log + issue)",
 			"namespace", namespace,
-			"deployment", deployment,
+			"workload", workloadKey,
 			"pod", podName,
 			"reason", reason,
 			"severity", severity,
 			"k8s_count", event.Count,
+			"flapping", occurrence.Flapping,
+			"reminder", reminder,
 		)
+		if occurrence.Flapping {
+			metrics.IssuesFlapping.Inc()
+		}
+		if reminder {
+			metrics.RemindersSent.Inc()
+		}
 	} else {
 		w.logger.Debug("sending event to sentry (log only)",
 			"namespace", namespace,
-			"deployment", deployment,
+			"workload", workloadKey,
 			"pod", podName,
 			"reason", reason,
 			"k8s_count", event.Count,
-			"threshold", w.filter.GetThreshold(reason),
+			"threshold", rule.Threshold,
 		)
 	}
 
+	var overrides map[string]string
+	if len(rule.Overrides) > 0 {
+		overrides = make(map[string]string, len(rule.Overrides))
+		for setting, o := range rule.Overrides {
+			overrides[setting] = o.String()
+		}
+	}
+
+	// Kubelet Events only name the container; describe it from the pod cache
+	if data.Container == nil {
+		data.Container = w.podContainer(namespace, event)
+	}
+
+	// Job failures don't say which pod failed; look it up for issues only
+	if shouldCreateIssue && event.InvolvedObject.Kind == "Job" && jobFailureReasons[reason] && (data.Job == nil || data.Job.Pod == "") {
+		if failure := w.lookupJobFailure(ctx, namespace, event.InvolvedObject.Name); failure != nil {
+			data.Job = failure
+		}
+	}
+
+	// Volumes, selectors and tolerations explain mount and scheduling failures
+	if data.Manifest == nil && shouldCreateIssue && w.wantsManifest(reason) {
+		data.Manifest = w.fetchManifest(ctx, namespace, event.InvolvedObject)
+	}
+
+	// The pod may be gone by the time someone runs kubectl logs --previous
+	if logs := w.logs.Load(); data.Logs == nil && shouldCreateIssue && logs.covers(namespace, event) {
+		data.Logs = w.fetchLogs(ctx, logs, namespace, podName, sentry.ContainerName(event, data.Container))
+	}
+
 	// Send to Sentry - logs for ALL events, issues only if meets threshold AND not deduped
-	w.sender.Send(sentry.EventData{
-		Event:          event,
-		Severity:       severity,
-		Count:          count,
-		FirstSeen:      firstSeen,
-		LastSeen:       lastSeen,
-		MeetsThreshold: shouldCreateIssue,
-	})
+	metrics.SendLatency.Observe(time.Since(eventLastSeen(event)).Seconds())
+	data.Event = event
+	data.Severity = severity
+	data.Count = count
+	data.FirstSeen = occurrence.FirstSeen
+	data.LastSeen = occurrence.LastSeen
+	data.Flapping = occurrence.Flapping
+	data.Recurrences = occurrence.Recurrences
+	data.Reminder = reminder
+	data.MeetsThreshold = shouldCreateIssue
+	data.Workload = wl
+	data.Project = rule.Project
+	data.Overrides = overrides
+	data.NamespaceLabels = namespaceLabels(ns)
+	w.sender.Send(data)
+}
+
+// escalateLevel returns the Sentry level one step more severe than level.
+func escalateLevel(level sentrygo.Level) sentrygo.Level {
+	switch level {
+	case sentrygo.LevelDebug:
+		return sentrygo.LevelInfo
+	case sentrygo.LevelInfo:
+		return sentrygo.LevelWarning
+	case sentrygo.LevelWarning:
+		return sentrygo.LevelError
+	default:
+		return sentrygo.LevelFatal
+	}
+}
+
+// resolveWorkload finds the workload owning the event's involved object.
+// Falls back to pod-name heuristics when the lookup fails (object already deleted,
+// RBAC forbids it, API server unavailable).
+func (w *Watcher) resolveWorkload(ctx context.Context, namespace string, event *corev1.Event) workload.Workload {
+	wl, err := w.resolver.Resolve(ctx, namespace, event.InvolvedObject)
+	if err != nil {
+		w.logger.Debug("workload lookup failed, using name heuristics",
+			"namespace", namespace,
+			"kind", event.InvolvedObject.Kind,
+			"name", event.InvolvedObject.Name,
+			"error", err,
+		)
+		guessed := sentry.GuessWorkload(event.InvolvedObject)
+		// Keep whatever metadata is known so selectors and opt-outs still apply
+		guessed.Chain = wl.Chain
+		if len(guessed.Chain) == 0 {
+			guessed.Chain = w.cachedChain(namespace, event.InvolvedObject)
+		}
+		return guessed
+	}
+	return wl
+}
+
+// mutedOccurrence describes a muted event from its current dedup window, if
+// any, without recording it.
+func (w *Watcher) mutedOccurrence(namespace, workloadKey, reason string) dedup.Occurrence {
+	if count, firstSeen, lastSeen, ok := w.dedup.GetStats(namespace, workloadKey, reason); ok {
+		return dedup.Occurrence{Count: count, FirstSeen: firstSeen, LastSeen: lastSeen}
+	}
+	now := time.Now()
+	return dedup.Occurrence{Count: 1, FirstSeen: now, LastSeen: now}
+}
+
+// cachedChain returns the involved pod's metadata from the pod informer's
+// cache, or nil if it isn't cached.
+func (w *Watcher) cachedChain(namespace string, ref corev1.ObjectReference) []workload.Object {
+	if ref.Kind != "Pod" {
+		return nil
+	}
+	pod := w.lookupPod(namespace, ref.Name)
+	if pod == nil {
+		return nil
+	}
+	return []workload.Object{{
+		Kind:        "Pod",
+		Name:        pod.Name,
+		Labels:      pod.Labels,
+		Annotations: pod.Annotations,
+		Fetched:     true,
+	}}
+}
+
+// lookupPod returns a pod from the pod informer's cache, or nil if pod status
+// isn't watched or the pod isn't cached.
+func (w *Watcher) lookupPod(namespace, name string) *corev1.Pod {
+	if w.pods == nil {
+		return nil
+	}
+	pod, err := w.pods.Pods(namespace).Get(name)
+	if err != nil {
+		return nil
+	}
+	return pod
+}
+
+// lookupNamespace returns the cached namespace, or nil if it's unknown
+// (cluster-scoped object, deleted namespace, or no informer running).
+func (w *Watcher) lookupNamespace(name string) *corev1.Namespace {
+	if w.namespaces == nil || name == "" {
+		return nil
+	}
+	ns, err := w.namespaces.Get(name)
+	if err != nil {
+		return nil
+	}
+	return ns
+}
+
+// namespaceLabels returns the namespace's labels, or nil if it's unknown.
+func namespaceLabels(ns *corev1.Namespace) map[string]string {
+	if ns == nil {
+		return nil
+	}
+	return ns.Labels
+}
+
+// eventLastSeen returns the most recent time the event was observed.
+func eventLastSeen(event *corev1.Event) time.Time {
+	if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+		return event.Series.LastObservedTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.EventTime.IsZero() {
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
 }
 
-func createK8sClient(kubeconfigPath string) (kubernetes.Interface, error) {
+// NewClient creates a Kubernetes client from the given kubeconfig path,
+// falling back to in-cluster config and then ~/.kube/config.
+func NewClient(kubeconfigPath string) (kubernetes.Interface, error) {
 	var config *rest.Config
 	var err error
 
@@ -0,0 +1,471 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	sentrygo "github.com/getsentry/sentry-go"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/imankulov/kube-sentry-events/internal/dedup"
+	"github.com/imankulov/kube-sentry-events/internal/filter"
+	"github.com/imankulov/kube-sentry-events/internal/health"
+	"github.com/imankulov/kube-sentry-events/internal/metrics"
+	"github.com/imankulov/kube-sentry-events/internal/sentry"
+	"github.com/imankulov/kube-sentry-events/internal/state"
+)
+
+// recordingSender collects everything the watcher sends.
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []sentry.EventData
+}
+
+func (r *recordingSender) Send(data sentry.EventData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, data)
+}
+
+func (r *recordingSender) issues() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, d := range r.sent {
+		if d.MeetsThreshold {
+			n++
+		}
+	}
+	return n
+}
+
+func newTestWatcher(objects ...runtime.Object) (*Watcher, *recordingSender) {
+	sender := &recordingSender{}
+	f := filter.New(nil, nil, []string{"CrashLoopBackOff"}, map[string]int32{"CrashLoopBackOff": 1})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	w := New(f, dedup.New(5*time.Minute), sender, logger, fake.NewClientset(objects...))
+	return w, sender
+}
+
+// waitFor polls cond until it returns true or the timeout expires.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition not met before timeout")
+}
+
+func newWatchedEvent(uid, resourceVersion string, lastSeen time.Time) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "event-" + uid,
+			Namespace:       "default",
+			UID:             types.UID(uid),
+			ResourceVersion: resourceVersion,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      "Pod",
+			Namespace: "default",
+			Name:      "worker-79c6dd4b57-wcdzt",
+		},
+		Reason:        "CrashLoopBackOff",
+		Type:          corev1.EventTypeWarning,
+		Count:         1,
+		LastTimestamp: metav1.NewTime(lastSeen),
+	}
+}
+
+func TestWatcher_HandleEventSkipsReplay(t *testing.T) {
+	w, sender := newTestWatcher()
+	ctx := context.Background()
+
+	event := newWatchedEvent("a", "100", time.Now())
+	w.handleEvent(ctx, event)
+	w.handleEvent(ctx, event.DeepCopy())
+
+	if len(sender.sent) != 1 {
+		t.Errorf("expected replayed event to be skipped, got %d sends", len(sender.sent))
+	}
+}
+
+func TestWatcher_PrimeEventSuppressesOldEvents(t *testing.T) {
+	w, sender := newTestWatcher()
+	ctx := context.Background()
+	cutoff := time.Now()
+
+	// Seen by the previous leader
+	w.primeEvent(ctx, newWatchedEvent("a", "100", cutoff.Add(-time.Hour)), cutoff)
+	if len(sender.sent) != 0 {
+		t.Fatalf("expected primed event not to be sent, got %d sends", len(sender.sent))
+	}
+
+	// The same problem recurs after takeover - still deduplicated
+	w.handleEvent(ctx, newWatchedEvent("a", "101", time.Now()))
+	if sender.issues() != 0 {
+		t.Error("expected recurring event to be deduplicated against primed state")
+	}
+}
+
+func TestWatcher_PrimeEventSendsRecentEvents(t *testing.T) {
+	w, sender := newTestWatcher()
+	ctx := context.Background()
+	cutoff := time.Now().Add(-time.Minute)
+
+	// Happened during the handover gap
+	w.primeEvent(ctx, newWatchedEvent("a", "100", time.Now()), cutoff)
+
+	if sender.issues() != 1 {
+		t.Errorf("expected event after cutoff to create an issue, got %d", sender.issues())
+	}
+}
+
+func TestWatcher_FlappingEscalatesSeverity(t *testing.T) {
+	w, sender := newTestWatcher()
+	w.dedup.SetFlapping(2, time.Hour)
+	w.SetEscalateFlapping(true)
+	ctx := context.Background()
+
+	w.handleEvent(ctx, newWatchedEvent("a", "100", time.Now()))
+	// The workload recovers and fails again
+	w.dedup.Forget("default", "Deployment/worker", "CrashLoopBackOff")
+	w.handleEvent(ctx, newWatchedEvent("b", "101", time.Now()))
+
+	if sender.issues() != 2 {
+		t.Fatalf("expected both windows to create an issue, got %d", sender.issues())
+	}
+	first, second := sender.sent[0], sender.sent[1]
+	if first.Flapping || first.Severity != sentrygo.LevelError {
+		t.Errorf("expected first issue not to flap, got flapping=%v severity=%s", first.Flapping, first.Severity)
+	}
+	if !second.Flapping || second.Recurrences != 2 || second.Severity != sentrygo.LevelFatal {
+		t.Errorf("expected escalated flapping issue, got flapping=%v recurrences=%d severity=%s",
+			second.Flapping, second.Recurrences, second.Severity)
+	}
+	if second.Count != 2 {
+		t.Errorf("expected count across windows, got %d", second.Count)
+	}
+}
+
+func TestWatcher_RemindsOngoingIssues(t *testing.T) {
+	w, sender := newTestWatcher()
+	clk := clocktesting.NewFakeClock(time.Now())
+	w.dedup = dedup.NewWithClock(5*time.Minute, clk)
+	t.Cleanup(w.dedup.Close)
+	w.dedup.SetRenotifyInterval(time.Hour)
+	ctx := context.Background()
+
+	// Keeps firing every four minutes for an hour
+	for i := 0; i <= 15; i++ {
+		event := newWatchedEvent("a", fmt.Sprint(100+i), clk.Now())
+		event.Message = fmt.Sprintf("Back-off restarting failed container (%d)", i)
+		w.handleEvent(ctx, event)
+		clk.Step(4 * time.Minute)
+	}
+
+	if sender.issues() != 2 {
+		t.Fatalf("expected the issue and one reminder, got %d issues", sender.issues())
+	}
+	last := sender.sent[len(sender.sent)-1]
+	if !last.MeetsThreshold || !last.Reminder || last.Count != 16 {
+		t.Errorf("expected a reminder with the updated count, got issue=%v reminder=%v count=%d",
+			last.MeetsThreshold, last.Reminder, last.Count)
+	}
+	if last.Event.Message != "Back-off restarting failed container (15)" {
+		t.Errorf("expected the latest message, got %q", last.Event.Message)
+	}
+}
+
+func TestWatcher_RunProcessesEventsAndReportsHealth(t *testing.T) {
+	w, sender := newTestWatcher(newWatchedEvent("a", "100", time.Now()))
+	checker := health.New(time.Minute)
+	checker.SetSentryReady(true)
+	w.SetHealth(checker)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+
+	waitFor(t, func() bool { return sender.issues() == 1 })
+	waitFor(t, func() bool { return checker.Ready() == nil })
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if checker.Ready() == nil {
+		t.Error("expected not ready after the watcher stopped")
+	}
+}
+
+func TestWatcher_RunRestoresDedupState(t *testing.T) {
+	store := state.NewFileStore(filepath.Join(t.TempDir(), "dedup.json"))
+
+	// The previous process already reported the event
+	previous, _ := newTestWatcher()
+	previous.dedup.SetStore(store)
+	previous.handleEvent(context.Background(), newWatchedEvent("a", "100", time.Now()))
+	if err := previous.dedup.Save(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	w, sender := newTestWatcher(newWatchedEvent("a", "101", time.Now()))
+	w.dedup.SetStore(store)
+	w.SetSnapshotInterval(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx)
+	}()
+	waitFor(t, func() bool {
+		sender.mu.Lock()
+		defer sender.mu.Unlock()
+		return len(sender.sent) == 1
+	})
+	cancel()
+	<-done
+
+	if sender.issues() != 0 {
+		t.Errorf("expected restored dedup state to suppress the issue, got %d issues", sender.issues())
+	}
+	if got := sender.sent[0].Count; got != 2 {
+		t.Errorf("expected count to continue from the snapshot, got %d", got)
+	}
+
+	// The snapshot is saved again on shutdown
+	restarted, _ := newTestWatcher()
+	restarted.dedup.SetStore(store)
+	if n, err := restarted.dedup.Load(context.Background()); err != nil || n != 1 {
+		t.Errorf("expected 1 entry saved on shutdown, got %d (%v)", n, err)
+	}
+}
+
+func TestWatcher_SetFilterKeepsDedupState(t *testing.T) {
+	w, sender := newTestWatcher()
+	ctx := context.Background()
+
+	w.handleEvent(ctx, newWatchedEvent("a", "100", time.Now()))
+
+	// Reload with a stricter severity; the next occurrence is still a duplicate
+	f := filter.New(nil, nil, []string{"CrashLoopBackOff"}, map[string]int32{"CrashLoopBackOff": 1})
+	f.SetSeverities(map[string]sentrygo.Level{"CrashLoopBackOff": sentrygo.LevelFatal})
+	w.SetFilter(f)
+
+	w.handleEvent(ctx, newWatchedEvent("a", "101", time.Now()))
+
+	if sender.issues() != 1 {
+		t.Errorf("expected dedup state to survive the filter swap, got %d issues", sender.issues())
+	}
+	if got := sender.sent[1].Severity; got != sentrygo.LevelFatal {
+		t.Errorf("expected new filter's severity, got %v", got)
+	}
+}
+
+func TestWatcher_RunHonoursNamespaceOptOut(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "default",
+		Annotations: map[string]string{filter.IgnoreAnnotation: "true"},
+	}}
+	w, sender := newTestWatcher(namespace, newWatchedEvent("a", "100", time.Now()))
+	optOuts := metrics.EventsFiltered.WithLabelValues(string(filter.RejectedOptOut))
+	before := testutil.ToFloat64(optOuts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = w.Run(ctx)
+	}()
+
+	// The rejection is counted once the event has been fully processed
+	waitFor(t, func() bool { return testutil.ToFloat64(optOuts) == before+1 })
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if len(sender.sent) != 0 {
+		t.Errorf("expected events in opted-out namespace to be dropped, got %d sends", len(sender.sent))
+	}
+}
+
+// startNamespaces syncs the watcher's namespace cache without running the event informer.
+func startNamespaces(t *testing.T, w *Watcher) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	factory := informers.NewSharedInformerFactory(w.client, 0)
+	t.Cleanup(func() {
+		cancel()
+		factory.Shutdown()
+	})
+	if err := w.startNamespaceInformer(ctx, factory); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startPods syncs the watcher's pod cache without running the event informer.
+func startPods(t *testing.T, w *Watcher) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	factory := informers.NewSharedInformerFactory(w.client, 0)
+	t.Cleanup(func() {
+		cancel()
+		factory.Shutdown()
+	})
+	pods := factory.Core().V1().Pods()
+	w.pods = pods.Lister()
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), pods.Informer().HasSynced) {
+		t.Fatal("failed to sync pod cache")
+	}
+}
+
+// forbidGets makes every get of resource fail as if RBAC denied it.
+func forbidGets(w *Watcher, resource string) {
+	w.client.(*fake.Clientset).PrependReactor("get", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: resource}, name, nil)
+	})
+}
+
+func TestWatcher_OptOutFromPodCacheWhenLookupFails(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "worker-79c6dd4b57-wcdzt",
+		Annotations: map[string]string{filter.IgnoreAnnotation: "true"},
+	}}
+	w, sender := newTestWatcher(pod)
+	forbidGets(w, "pods")
+	startPods(t, w)
+
+	w.processEvent(context.Background(), newWatchedEvent("a", "100", time.Now()))
+
+	if len(sender.sent) != 0 {
+		t.Errorf("expected the cached pod's opt-out to apply, got %d sends", len(sender.sent))
+	}
+}
+
+func TestWatcher_UnknownMetadata(t *testing.T) {
+	w, sender := newTestWatcher()
+	forbidGets(w, "pods")
+	ctx := context.Background()
+
+	// Without an object selector, an event whose metadata is unknown is sent
+	w.processEvent(ctx, newWatchedEvent("a", "100", time.Now()))
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected event with unknown metadata to be sent, got %d sends", len(sender.sent))
+	}
+
+	// With one, it can't be shown to match and is dropped
+	f := filter.New(nil, nil, []string{"CrashLoopBackOff"}, map[string]int32{"CrashLoopBackOff": 1})
+	if err := f.SetSelectors("", "", "team=payments", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.SetFilter(f)
+	w.processEvent(ctx, newWatchedEvent("b", "101", time.Now()))
+	if len(sender.sent) != 1 {
+		t.Errorf("expected event with unknown metadata to be dropped by the object selector, got %d sends", len(sender.sent))
+	}
+}
+
+func TestWatcher_AnnotationOverrides(t *testing.T) {
+	isController := true
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "default",
+		Annotations: map[string]string{filter.SeverityAnnotation: "info", filter.ProjectAnnotation: "platform"},
+	}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "worker-79c6dd4b57-wcdzt",
+		OwnerReferences: []metav1.OwnerReference{
+			{Kind: "Deployment", Name: "worker", Controller: &isController},
+		},
+	}}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "worker",
+		Annotations: map[string]string{filter.SeverityAnnotation: "fatal"},
+	}}
+	w, sender := newTestWatcher(namespace, pod, deployment)
+	startNamespaces(t, w)
+
+	w.handleEvent(context.Background(), newWatchedEvent("a", "100", time.Now()))
+
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(sender.sent))
+	}
+	sent := sender.sent[0]
+	if sent.Severity != sentrygo.LevelFatal {
+		t.Errorf("expected workload severity to win, got %v", sent.Severity)
+	}
+	if sent.Project != "platform" {
+		t.Errorf("expected namespace project, got %q", sent.Project)
+	}
+	if got := sent.Overrides["severity"]; got != "fatal (Deployment/worker)" {
+		t.Errorf("unexpected severity override %q", got)
+	}
+}
+
+func TestWatcher_IssueAfterMuteExpires(t *testing.T) {
+	muteUntil := time.Now().Add(200 * time.Millisecond)
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "default",
+		Annotations: map[string]string{filter.MuteUntilAnnotation: muteUntil.Format(time.RFC3339Nano)},
+	}}
+	w, sender := newTestWatcher(namespace)
+	startNamespaces(t, w)
+	ctx := context.Background()
+
+	// The problem keeps firing while muted...
+	w.processEvent(ctx, newWatchedEvent("a", "100", time.Now()))
+	w.processEvent(ctx, newWatchedEvent("b", "101", time.Now()))
+	if sender.issues() != 0 {
+		t.Fatalf("expected no issues while muted, got %d", sender.issues())
+	}
+
+	// ...and past the end of the mute, when it's reported
+	time.Sleep(time.Until(muteUntil))
+	w.processEvent(ctx, newWatchedEvent("c", "102", time.Now()))
+	if sender.issues() != 1 {
+		t.Errorf("expected an issue once the mute expired, got %d", sender.issues())
+	}
+}
+
+func TestWatcher_MuteUntilSuppressesIssues(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "default",
+		Annotations: map[string]string{filter.MuteUntilAnnotation: time.Now().Add(time.Hour).Format(time.RFC3339)},
+	}}
+	w, sender := newTestWatcher(namespace)
+	startNamespaces(t, w)
+
+	w.handleEvent(context.Background(), newWatchedEvent("a", "100", time.Now()))
+
+	if len(sender.sent) != 1 {
+		t.Fatalf("expected the log to still be sent, got %d sends", len(sender.sent))
+	}
+	if sender.issues() != 0 {
+		t.Errorf("expected muted event not to create an issue, got %d", sender.issues())
+	}
+}
@@ -0,0 +1,161 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/imankulov/kube-sentry-events/internal/cache"
+)
+
+const (
+	// maxDepth bounds the ownerReference walk to protect against cycles.
+	maxDepth = 5
+)
+
+// Object is one link in an ownerReference chain.
+type Object struct {
+	Kind        string
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Fetched is false for owners of kinds we don't look up (e.g. CRDs),
+	// whose labels and annotations are unknown.
+	Fetched bool
+}
+
+// Workload identifies the top-level controller owning a Kubernetes object.
+type Workload struct {
+	Kind string
+	Name string
+	// Chain lists every object walked from the involved object up to the workload,
+	// e.g. Pod/web-7d9f8c6b5-abcde, ReplicaSet/web-7d9f8c6b5, Deployment/web.
+	Chain []Object
+}
+
+// ChainNames returns the chain as "Kind/name" strings.
+func (w Workload) ChainNames() []string {
+	names := make([]string, len(w.Chain))
+	for i, obj := range w.Chain {
+		names[i] = obj.Kind + "/" + obj.Name
+	}
+	return names
+}
+
+// cacheEntry holds an object's metadata and controller owner (nil if it has none).
+type cacheEntry struct {
+	labels      map[string]string
+	annotations map[string]string
+	owner       *metav1.OwnerReference
+}
+
+// Resolver walks ownerReferences (Pod -> ReplicaSet -> Deployment, Pod -> Job -> CronJob, etc.)
+// to find the workload an event belongs to. Lookups are cached for the configured TTL.
+type Resolver struct {
+	client kubernetes.Interface
+	cache  *cache.Cache[cacheEntry]
+}
+
+// NewResolver creates a new workload resolver.
+func NewResolver(client kubernetes.Interface, ttl time.Duration) *Resolver {
+	return &Resolver{
+		client: client,
+		cache:  cache.New[cacheEntry](ttl, cache.DefaultMaxEntries),
+	}
+}
+
+// Resolve returns the top-level workload owning the referenced object.
+// An error is returned if any object in the chain cannot be fetched (e.g. it was
+// already deleted or RBAC forbids the lookup); callers should fall back to heuristics.
+// The workload's Chain then holds the objects fetched before the failure.
+func (r *Resolver) Resolve(ctx context.Context, namespace string, ref corev1.ObjectReference) (Workload, error) {
+	kind, name := ref.Kind, ref.Name
+	var chain []Object
+
+	for i := 0; i < maxDepth; i++ {
+		if !isSupportedKind(kind) {
+			// Owned by something we don't know how to fetch (e.g. a CRD) - stop here
+			chain = append(chain, Object{Kind: kind, Name: name})
+			break
+		}
+
+		e, err := r.lookup(ctx, kind, namespace, name)
+		if err != nil {
+			return Workload{Chain: chain}, err
+		}
+		chain = append(chain, Object{
+			Kind:        kind,
+			Name:        name,
+			Labels:      e.labels,
+			Annotations: e.annotations,
+			Fetched:     true,
+		})
+		if e.owner == nil {
+			break
+		}
+
+		kind, name = e.owner.Kind, e.owner.Name
+	}
+
+	return Workload{Kind: kind, Name: name, Chain: chain}, nil
+}
+
+// Size returns the current number of cached lookups.
+func (r *Resolver) Size() int {
+	return r.cache.Len()
+}
+
+func (r *Resolver) lookup(ctx context.Context, kind, namespace, name string) (cacheEntry, error) {
+	key := kind + "/" + namespace + "/" + name
+	if e, ok := r.cache.Get(key); ok {
+		return e, nil
+	}
+
+	meta, err := r.fetch(ctx, kind, namespace, name)
+	if err != nil {
+		return cacheEntry{}, fmt.Errorf("failed to get %s %s/%s: %w", kind, namespace, name, err)
+	}
+	e := cacheEntry{
+		labels:      meta.GetLabels(),
+		annotations: meta.GetAnnotations(),
+		owner:       metav1.GetControllerOf(meta),
+	}
+	r.cache.Set(key, e)
+
+	return e, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, kind, namespace, name string) (metav1.Object, error) {
+	opts := metav1.GetOptions{}
+	switch kind {
+	case "Pod":
+		return r.client.CoreV1().Pods(namespace).Get(ctx, name, opts)
+	case "ReplicationController":
+		return r.client.CoreV1().ReplicationControllers(namespace).Get(ctx, name, opts)
+	case "ReplicaSet":
+		return r.client.AppsV1().ReplicaSets(namespace).Get(ctx, name, opts)
+	case "Deployment":
+		return r.client.AppsV1().Deployments(namespace).Get(ctx, name, opts)
+	case "StatefulSet":
+		return r.client.AppsV1().StatefulSets(namespace).Get(ctx, name, opts)
+	case "DaemonSet":
+		return r.client.AppsV1().DaemonSets(namespace).Get(ctx, name, opts)
+	case "Job":
+		return r.client.BatchV1().Jobs(namespace).Get(ctx, name, opts)
+	case "CronJob":
+		return r.client.BatchV1().CronJobs(namespace).Get(ctx, name, opts)
+	}
+	return nil, fmt.Errorf("unsupported kind %q", kind)
+}
+
+func isSupportedKind(kind string) bool {
+	switch kind {
+	case "Pod", "ReplicationController", "ReplicaSet", "Deployment", "StatefulSet", "DaemonSet", "Job", "CronJob":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package workload
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func controllerRef(kind, name string) []metav1.OwnerReference {
+	isController := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &isController}}
+}
+
+func newMeta(namespace, name string, owners []metav1.OwnerReference) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Namespace: namespace, Name: name, OwnerReferences: owners}
+}
+
+func podRef(namespace, name string) corev1.ObjectReference {
+	return corev1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: name}
+}
+
+func TestResolver_DeploymentPod(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: newMeta("default", "web-7d9f8c6b5-abcde", controllerRef("ReplicaSet", "web-7d9f8c6b5"))},
+		&appsv1.ReplicaSet{ObjectMeta: newMeta("default", "web-7d9f8c6b5", controllerRef("Deployment", "web"))},
+		&appsv1.Deployment{ObjectMeta: newMeta("default", "web", nil)},
+	)
+	r := NewResolver(client, time.Minute)
+
+	wl, err := r.Resolve(context.Background(), "default", podRef("default", "web-7d9f8c6b5-abcde"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wl.Kind != "Deployment" || wl.Name != "web" {
+		t.Errorf("expected Deployment/web, got %s/%s", wl.Kind, wl.Name)
+	}
+	if len(wl.Chain) != 3 {
+		t.Errorf("expected chain of 3, got %v", wl.Chain)
+	}
+}
+
+func TestResolver_CronJobPod(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: newMeta("batch", "report-28391-x7k2p", controllerRef("Job", "report-28391"))},
+		&batchv1.Job{ObjectMeta: newMeta("batch", "report-28391", controllerRef("CronJob", "report"))},
+		&batchv1.CronJob{ObjectMeta: newMeta("batch", "report", nil)},
+	)
+	r := NewResolver(client, time.Minute)
+
+	wl, err := r.Resolve(context.Background(), "batch", podRef("batch", "report-28391-x7k2p"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wl.Kind != "CronJob" || wl.Name != "report" {
+		t.Errorf("expected CronJob/report, got %s/%s", wl.Kind, wl.Name)
+	}
+}
+
+func TestResolver_StatefulSetPod(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: newMeta("default", "db-0", controllerRef("StatefulSet", "db"))},
+		&appsv1.StatefulSet{ObjectMeta: newMeta("default", "db", nil)},
+	)
+	r := NewResolver(client, time.Minute)
+
+	wl, err := r.Resolve(context.Background(), "default", podRef("default", "db-0"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wl.Kind != "StatefulSet" || wl.Name != "db" {
+		t.Errorf("expected StatefulSet/db, got %s/%s", wl.Kind, wl.Name)
+	}
+}
+
+func TestResolver_BarePod(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: newMeta("default", "debug-shell", nil)},
+	)
+	r := NewResolver(client, time.Minute)
+
+	wl, err := r.Resolve(context.Background(), "default", podRef("default", "debug-shell"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wl.Kind != "Pod" || wl.Name != "debug-shell" {
+		t.Errorf("expected Pod/debug-shell, got %s/%s", wl.Kind, wl.Name)
+	}
+}
+
+func TestResolver_UnknownOwnerKind(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: newMeta("default", "canary-abc", controllerRef("Rollout", "canary"))},
+	)
+	r := NewResolver(client, time.Minute)
+
+	wl, err := r.Resolve(context.Background(), "default", podRef("default", "canary-abc"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wl.Kind != "Rollout" || wl.Name != "canary" {
+		t.Errorf("expected Rollout/canary, got %s/%s", wl.Kind, wl.Name)
+	}
+}
+
+func TestResolver_MissingObject(t *testing.T) {
+	client := fake.NewClientset()
+	r := NewResolver(client, time.Minute)
+
+	_, err := r.Resolve(context.Background(), "default", podRef("default", "gone-pod"))
+	if err == nil {
+		t.Error("expected error for missing pod")
+	}
+}
+
+func TestResolver_PartialChain(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: newMeta("default", "web-7d9f8c6b5-abcde", controllerRef("ReplicaSet", "web-7d9f8c6b5"))},
+	)
+	r := NewResolver(client, time.Minute)
+
+	wl, err := r.Resolve(context.Background(), "default", podRef("default", "web-7d9f8c6b5-abcde"))
+	if err == nil {
+		t.Fatal("expected error for missing ReplicaSet")
+	}
+	if len(wl.Chain) != 1 || wl.Chain[0].Kind != "Pod" || !wl.Chain[0].Fetched {
+		t.Errorf("expected the fetched pod in the chain, got %+v", wl.Chain)
+	}
+}
+
+func TestResolver_CachesLookups(t *testing.T) {
+	objects := []runtime.Object{
+		&corev1.Pod{ObjectMeta: newMeta("default", "db-0", controllerRef("StatefulSet", "db"))},
+		&appsv1.StatefulSet{ObjectMeta: newMeta("default", "db", nil)},
+	}
+	client := fake.NewClientset(objects...)
+	r := NewResolver(client, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.Resolve(context.Background(), "default", podRef("default", "db-0")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := len(client.Actions()); got != 2 {
+		t.Errorf("expected 2 API calls (pod + statefulset), got %d", got)
+	}
+	if r.Size() != 2 {
+		t.Errorf("expected 2 cached entries, got %d", r.Size())
+	}
+}
+
+func TestResolver_ChainCarriesMetadata(t *testing.T) {
+	deploymentMeta := newMeta("default", "web", nil)
+	deploymentMeta.Labels = map[string]string{"team": "payments"}
+	deploymentMeta.Annotations = map[string]string{"kube-sentry-events/ignore": "true"}
+
+	client := fake.NewClientset(
+		&corev1.Pod{ObjectMeta: newMeta("default", "web-7d9f8c6b5-abcde", controllerRef("ReplicaSet", "web-7d9f8c6b5"))},
+		&appsv1.ReplicaSet{ObjectMeta: newMeta("default", "web-7d9f8c6b5", controllerRef("Deployment", "web"))},
+		&appsv1.Deployment{ObjectMeta: deploymentMeta},
+	)
+	r := NewResolver(client, time.Minute)
+
+	wl, err := r.Resolve(context.Background(), "default", podRef("default", "web-7d9f8c6b5-abcde"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	top := wl.Chain[len(wl.Chain)-1]
+	if top.Labels["team"] != "payments" || top.Annotations["kube-sentry-events/ignore"] != "true" {
+		t.Errorf("expected deployment metadata in chain, got %+v", top)
+	}
+
+	names := wl.ChainNames()
+	expected := []string{"Pod/web-7d9f8c6b5-abcde", "ReplicaSet/web-7d9f8c6b5", "Deployment/web"}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("ChainNames()[%d] = %s, want %s", i, names[i], expected[i])
+		}
+	}
+}